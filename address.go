@@ -0,0 +1,60 @@
+package pool
+
+// SetAddress moves the pool to a new address, for example after a device has been given a
+// new IP address. New connections are made to addr straight away and idle connections to the
+// old address are replaced, connections that are checked out keep working until they are
+// released and are then replaced, so commands in progress aren't interrupted. It has no effect
+// on pools using Config.Addresses. Config keeps the address the pool was created with, use
+// ActiveAddress to find the one in use
+func (p *ConnectionPool) SetAddress(addr string) {
+	p.mu.Lock()
+	if addr == p.address {
+		p.mu.Unlock()
+		return
+	}
+	p.address = addr
+	p.resolved = nil
+	p.failoverIndex = 0
+	p.failoverFailures = 0
+	p.mu.Unlock()
+	p.migrate()
+}
+
+// migrate replaces the idle and standby connections that aren't connected to the address the
+// pool is using, connections that are checked out are replaced when they are released
+func (p *ConnectionPool) migrate() {
+	p.mu.Lock()
+	var moved, idle []*pooledConn
+	for _, pc := range p.idle {
+		if !p.usingLocked(pc.addr) {
+			moved = append(moved, pc)
+		} else {
+			idle = append(idle, pc)
+		}
+	}
+	p.idle = idle
+	var standby, kept []*pooledConn
+	for _, pc := range p.standby {
+		if !p.usingLocked(pc.addr) {
+			standby = append(standby, pc)
+		} else {
+			kept = append(kept, pc)
+		}
+	}
+	p.standby = kept
+	p.mu.Unlock()
+
+	for _, pc := range standby {
+		p.closeConn(pc, closeAddressChanged)
+	}
+	for _, pc := range moved {
+		p.discard(pc, closeAddressChanged)
+		p.refill()
+	}
+
+	p.mu.Lock()
+	if p.unavailableLocked() == nil {
+		p.topUpStandbyLocked()
+	}
+	p.mu.Unlock()
+}
@@ -0,0 +1,63 @@
+package pool_test
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetAddressMigratesConnections(t *testing.T) {
+	var mu sync.Mutex
+	dialed := map[string]int{}
+	closed := map[string]int{}
+	p, err := pool.NewPool(pool.Config{
+		Size:    3,
+		Address: "10.0.0.5:23",
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			dialed[cfg.Address]++
+			addr := cfg.Address
+			return &mockConn{CloseCalled: func(*mockConn) {
+				mu.Lock()
+				closed[addr]++
+				mu.Unlock()
+			}}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	counts := func(m map[string]int, addr string) func() int {
+		return func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return m[addr]
+		}
+	}
+
+	inFlight, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+
+	p.SetAddress("10.0.0.9:23")
+	require.Equal(t, "10.0.0.9:23", p.ActiveAddress())
+	require.Equal(t, "10.0.0.5:23", p.Config.Address)
+
+	// The idle connections move straight away, the checked out one keeps working
+	require.Eventually(t, func() bool { return counts(dialed, "10.0.0.9:23")() == 2 }, time.Second, time.Millisecond)
+	require.Equal(t, 2, counts(closed, "10.0.0.5:23")())
+
+	require.Nil(t, p.Release(inFlight, nil))
+	require.Eventually(t, func() bool { return counts(dialed, "10.0.0.9:23")() == 3 }, time.Second, time.Millisecond)
+	require.Equal(t, 3, counts(closed, "10.0.0.5:23")())
+	require.Equal(t, 3, counts(dialed, "10.0.0.5:23")())
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, nil))
+	<-p.Close()
+}
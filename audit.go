@@ -0,0 +1,135 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// AuditOp is the kind of operation an AuditEntry records
+type AuditOp int
+
+const (
+	// AuditGet is a call to Get, GetContext, GetUntil or TryGet
+	AuditGet AuditOp = iota
+
+	// AuditRelease is a connection being released back to the pool
+	AuditRelease
+)
+
+func (op AuditOp) String() string {
+	switch op {
+	case AuditGet:
+		return "get"
+	case AuditRelease:
+		return "release"
+	}
+	return fmt.Sprintf("AuditOp(%d)", int(op))
+}
+
+// MarshalText marshals the operation as its name, so it appears as a string in JSON
+func (op AuditOp) MarshalText() ([]byte, error) {
+	return []byte(op.String()), nil
+}
+
+// AuditEntry is one of the recent operations recorded when Config.AuditSize is set
+type AuditEntry struct {
+	Op AuditOp
+
+	// Time is when the operation finished
+	Time time.Time
+
+	// Caller is the file:line outside this package the operation was called from
+	Caller string
+
+	// ConnID is the Connection.ID of the connection, 0 if Get failed
+	ConnID uint64
+
+	// Duration is how long Get waited or how long the connection was checked out for
+	Duration time.Duration
+
+	// Err is the error Get returned or the error the connection was released with
+	Err error
+}
+
+// MarshalJSON marshals the entry with Err as the error's message, left out if there wasn't
+// an error, and Duration in nanoseconds
+func (e AuditEntry) MarshalJSON() ([]byte, error) {
+	v := struct {
+		Op       AuditOp       `json:"op"`
+		Time     time.Time     `json:"time"`
+		Caller   string        `json:"caller"`
+		ConnID   uint64        `json:"conn_id"`
+		Duration time.Duration `json:"duration_ns"`
+		Err      string        `json:"error,omitempty"`
+	}{Op: e.Op, Time: e.Time, Caller: e.Caller, ConnID: e.ConnID, Duration: e.Duration}
+	if e.Err != nil {
+		v.Err = e.Err.Error()
+	}
+	return json.Marshal(v)
+}
+
+// pkgPrefix is the prefix of the names of the functions in this package
+var pkgPrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	name := runtime.FuncForPC(pc).Name()
+	slash := strings.LastIndex(name, "/")
+	return name[:slash+strings.Index(name[slash:], ".")+1]
+}()
+
+// auditCaller returns the file:line of the first caller outside this package if
+// Config.AuditSize is set, an empty string otherwise. Only the program counters are
+// captured, not the whole stack, to keep it cheap enough to call on every Get
+func (p *ConnectionPool) auditCaller() string {
+	if p.Config.AuditSize <= 0 {
+		return ""
+	}
+	var pcs [16]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkgPrefix) {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+// auditLocked adds an entry to the audit ring, overwriting the oldest once it is full.
+// p.mu must be held
+func (p *ConnectionPool) auditLocked(e AuditEntry) {
+	if p.Config.AuditSize <= 0 {
+		return
+	}
+	e.Time = time.Now()
+	if len(p.auditRing) < p.Config.AuditSize {
+		p.auditRing = append(p.auditRing, e)
+		return
+	}
+	p.auditRing[p.auditNext] = e
+	p.auditNext = (p.auditNext + 1) % len(p.auditRing)
+}
+
+// audit is like auditLocked for callers that don't hold p.mu
+func (p *ConnectionPool) audit(e AuditEntry) {
+	if p.Config.AuditSize <= 0 {
+		return
+	}
+	p.mu.Lock()
+	p.auditLocked(e)
+	p.mu.Unlock()
+}
+
+// auditTrailLocked returns a copy of the audit ring, oldest first. p.mu must be held
+func (p *ConnectionPool) auditTrailLocked() []AuditEntry {
+	if len(p.auditRing) == 0 {
+		return nil
+	}
+	trail := make([]AuditEntry, 0, len(p.auditRing))
+	trail = append(trail, p.auditRing[p.auditNext:]...)
+	return append(trail, p.auditRing[:p.auditNext]...)
+}
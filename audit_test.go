@@ -0,0 +1,64 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditRecordsRecentOperations(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size:      1,
+		AuditSize: 3,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	_, err = p.TryGet()
+	require.Equal(t, pool.ErrExhausted, err)
+	require.Nil(t, c.Close())
+	c, err = p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, errors.New("connection reset")))
+
+	// Only the last three are kept
+	audit := p.Dump().Audit
+	require.Len(t, audit, 3)
+	require.Equal(t, pool.AuditRelease, audit[0].Op)
+	require.Equal(t, pool.AuditGet, audit[1].Op)
+	require.Equal(t, c.ID(), audit[1].ConnID)
+	require.Equal(t, pool.AuditRelease, audit[2].Op)
+	require.EqualError(t, audit[2].Err, "connection reset")
+	for _, e := range audit {
+		require.True(t, strings.Contains(e.Caller, "audit_test.go:"), e.Caller)
+	}
+	require.Contains(t, p.Dump().String(), "recent operations:")
+}
+
+func TestAuditIsOffByDefault(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, c.Close())
+	require.Nil(t, p.Dump().Audit)
+}
@@ -0,0 +1,86 @@
+package pool
+
+import (
+	"errors"
+	"time"
+)
+
+// AutoScaleConfig configures the pool's autoscaler. Every Interval it looks at how long Get
+// calls have waited for a connection and what fraction of the pool is in use, then grows or
+// shrinks the pool by one connection, staying within Min and Max
+type AutoScaleConfig struct {
+	// Min and Max bound the pool size
+	Min int
+	Max int
+
+	// Interval is how often the autoscaler checks the pool
+	Interval time.Duration
+
+	// GrowWait is the average time Get calls can wait for a connection before the pool
+	// grows. The pool also grows when callers are still waiting at the time of the check
+	GrowWait time.Duration
+
+	// ShrinkUtilization is the fraction of connections in use, between 0 and 1, below which
+	// the pool shrinks. Zero means the pool never shrinks
+	ShrinkUtilization float64
+
+	// OnResize if set is called every time the autoscaler changes the pool size
+	OnResize func(oldSize, newSize int)
+}
+
+func (c AutoScaleConfig) validate() error {
+	switch {
+	case c.Min <= 0:
+		return errors.New("Min must be greater than 0")
+	case c.Max < c.Min:
+		return errors.New("Max must not be less than Min")
+	case c.Interval <= 0:
+		return errors.New("Interval must be greater than 0")
+	case c.ShrinkUtilization < 0 || c.ShrinkUtilization > 1:
+		return errors.New("ShrinkUtilization must be between 0 and 1")
+	}
+	return nil
+}
+
+// autoScale runs the autoscaler until stop is closed
+func (p *ConnectionPool) autoScale(cfg AutoScaleConfig, stop chan struct{}) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		p.mu.Lock()
+		size := p.size
+		var avgWait time.Duration
+		if p.waitCount > 0 {
+			avgWait = p.waitTotal / time.Duration(p.waitCount)
+		}
+		p.waitTotal, p.waitCount = 0, 0
+		waiting := len(p.waiters) > 0 && !p.paused
+		utilization := float64(len(p.inUse)) / float64(size)
+		p.mu.Unlock()
+
+		newSize := size
+		switch {
+		case (waiting || avgWait > cfg.GrowWait) && size < cfg.Max:
+			newSize = size + 1
+		case utilization < cfg.ShrinkUtilization && size > cfg.Min:
+			newSize = size - 1
+		}
+		if newSize == size {
+			continue
+		}
+
+		if err := p.Resize(newSize); err != nil {
+			return
+		}
+		if cfg.OnResize != nil {
+			p.safely("OnResize", func() { cfg.OnResize(size, newSize) })
+		}
+	}
+}
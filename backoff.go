@@ -0,0 +1,60 @@
+package pool
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// Backoff is a retry policy where the delay before each new connection attempt grows
+// exponentially, so a device that is offline isn't retried constantly. The delay after the
+// first failed attempt is Initial, each later one is Multiplier times longer up to Max.
+// Jitter randomly shortens each delay by up to that fraction so pools that lost their
+// devices at the same time don't all retry in step
+type Backoff struct {
+	Initial    time.Duration
+	Multiplier float64
+	Max        time.Duration
+
+	// Jitter is between 0, no jitter, and 1, where a delay can be anything up to the
+	// calculated one
+	Jitter float64
+}
+
+func (b Backoff) validate() error {
+	switch {
+	case b.Initial <= 0:
+		return errors.New("Initial must be greater than 0")
+	case b.Multiplier < 1:
+		return errors.New("Multiplier must be at least 1")
+	case b.Max != 0 && b.Max < b.Initial:
+		return errors.New("Max must not be less than Initial")
+	case b.Jitter < 0 || b.Jitter > 1:
+		return errors.New("Jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// Delay returns how long to wait after the given failed attempt, attempt counts from 1
+func (b Backoff) Delay(attempt int) time.Duration {
+	d := float64(b.Initial)
+	for i := 1; i < attempt && (b.Max == 0 || d < float64(b.Max)); i++ {
+		d *= b.Multiplier
+	}
+	if b.Max != 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		d -= d * b.Jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// retryDelay returns how long to wait before trying to create a connection again after the
+// given failed attempt
+func (p *ConnectionPool) retryDelay(attempt int) time.Duration {
+	if p.Config.Backoff != nil {
+		return p.Config.Backoff.Delay(attempt)
+	}
+	return p.Config.RetryDuration
+}
@@ -0,0 +1,38 @@
+package pool_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffDelayGrowsUpToMax(t *testing.T) {
+	b := pool.Backoff{Initial: 10 * time.Millisecond, Multiplier: 2, Max: 50 * time.Millisecond}
+	require.Equal(t, 10*time.Millisecond, b.Delay(1))
+	require.Equal(t, 20*time.Millisecond, b.Delay(2))
+	require.Equal(t, 40*time.Millisecond, b.Delay(3))
+	require.Equal(t, 50*time.Millisecond, b.Delay(4))
+	require.Equal(t, 50*time.Millisecond, b.Delay(100))
+}
+
+func TestBackoffJitterShortensTheDelay(t *testing.T) {
+	b := pool.Backoff{Initial: 100 * time.Millisecond, Multiplier: 1, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d := b.Delay(1)
+		require.True(t, d > 50*time.Millisecond && d <= 100*time.Millisecond, "delay %s", d)
+	}
+}
+
+func TestBackoffConfigIsValidated(t *testing.T) {
+	_, err := pool.NewPool(pool.Config{
+		Size:    1,
+		Backoff: &pool.Backoff{Initial: time.Second, Multiplier: 0.5},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.NotNil(t, err)
+}
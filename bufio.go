@@ -0,0 +1,53 @@
+package pool
+
+import (
+	"bufio"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrUnreadData is what a connection is treated as having been released with when its
+// buffered reader, see Config.ReadBufferSize, still holds data nobody has read
+var ErrUnreadData = errors.New("unread data left in the connection's read buffer")
+
+// connReader reads from the underlying connection for its buffered reader, counting the bytes
+// and errors the same way Connection.Read does
+type connReader struct {
+	pc *pooledConn
+}
+
+func (r connReader) Read(b []byte) (int, error) {
+	pc := r.pc
+	n, err := pc.Conn.Read(b)
+	atomic.AddInt64(&pc.bytesRead, int64(n))
+	if err != nil {
+		atomic.AddInt64(&pc.errors, 1)
+		if p := pc.owner; p != nil && p.Config.MarkBadOnIOError && p.isBadConn(err) {
+			p.mu.Lock()
+			c := p.inUse[pc]
+			p.mu.Unlock()
+			if c != nil {
+				p.markBad(c)
+			}
+		}
+	}
+	return n, err
+}
+
+// newReaderLocked gives the connection a buffered reader if Config.ReadBufferSize is set, p.mu
+// must be held
+func (p *ConnectionPool) newReaderLocked(pc *pooledConn) {
+	if p.Config.ReadBufferSize > 0 {
+		pc.reader = bufio.NewReaderSize(connReader{pc: pc}, p.Config.ReadBufferSize)
+	}
+}
+
+// Reader returns the buffered reader that belongs to the underlying connection when
+// Config.ReadBufferSize is set, nil otherwise. The same reader is returned for every lease
+// on the connection so data it has buffered isn't lost between checkouts, Read on the
+// lease reads through it as well. Reads through it count towards the connection's
+// statistics. A connection released with data still buffered is thrown away with
+// ErrUnreadData, so the next caller never sees a reply meant for somebody else
+func (c *Connection) Reader() *bufio.Reader {
+	return c.pc.reader
+}
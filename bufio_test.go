@@ -0,0 +1,101 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderIsKeptAcrossCheckouts(t *testing.T) {
+	peers := make(chan net.Conn, 2)
+	p, err := pool.NewPool(pool.Config{
+		Size:           1,
+		ReadBufferSize: 64,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			c, peer := net.Pipe()
+			peers <- peer
+			return c, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	peer := <-peers
+	defer func() {
+		<-p.Close()
+		peer.Close()
+	}()
+
+	c1, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	go peer.Write([]byte("temp=21\n"))
+	line, err := c1.Reader().ReadString('\n')
+	require.Nil(t, err)
+	require.Equal(t, "temp=21\n", line)
+	r := c1.Reader()
+	require.Nil(t, c1.Close())
+
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.True(t, r == c2.Reader())
+
+	// Leaving a reply in the buffer throws the connection away
+	go peer.Write([]byte("ok\nok\n"))
+	b := make([]byte, 3)
+	_, err = c2.Read(b)
+	require.Nil(t, err)
+	require.Equal(t, "ok\n", string(b))
+	require.Nil(t, c2.Close())
+	require.False(t, c2.IsHealthy())
+}
+
+func TestReaderIsNilByDefault(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, c.Reader())
+	require.Nil(t, c.Close())
+}
+
+func TestReleasingWithUnreadDataReportsErrUnreadData(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size:           1,
+		ReadBufferSize: 16,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			c, peer := net.Pipe()
+			go func() {
+				peer.Write([]byte("a\nb\n"))
+				peer.Close()
+			}()
+			return c, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+	events := p.Subscribe()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	_, err = c.Reader().ReadString('\n')
+	require.Nil(t, err)
+	require.Nil(t, c.Close())
+	for e := range events {
+		if e.Type == pool.ConnEvicted {
+			require.True(t, errors.Is(e.Err, pool.ErrUnreadData), e.Err)
+			break
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package pool
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrCircuitOpen is returned when trying to get a connection while the circuit breaker is
+// open because the pool can't connect to the device
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker trips after Failures connection attempts in a row have failed. While it is
+// open, for CoolDown, Get calls that would have to wait for a new connection fail straight
+// away with ErrCircuitOpen, idle connections are still handed out. The pool keeps trying to
+// connect in the background, once CoolDown is over Get calls wait again and the next failed
+// attempt trips the breaker straight away, until an attempt succeeds
+type CircuitBreaker struct {
+	Failures int
+	CoolDown time.Duration
+}
+
+func (b CircuitBreaker) validate() error {
+	switch {
+	case b.Failures <= 0:
+		return errors.New("Failures must be greater than 0")
+	case b.CoolDown <= 0:
+		return errors.New("CoolDown must be greater than 0")
+	}
+	return nil
+}
+
+// dialSucceededLocked resets the circuit breaker after a connection has been created, p.mu
+// must be held
+func (p *ConnectionPool) dialSucceededLocked() {
+	p.dialFailures = 0
+	p.circuitOpenUntil = time.Time{}
+}
+
+// dialFailedLocked counts a failed connection attempt, tripping the circuit breaker if there
+// have been too many in a row. Tripping it fails every queued waiter if there are no open
+// connections that could be released to them, p.mu must be held
+func (p *ConnectionPool) dialFailedLocked(err error) {
+	p.lastDialErr = err
+	p.dialFailures++
+	cb := p.Config.CircuitBreaker
+	if cb == nil || p.dialFailures < cb.Failures || p.circuitOpenLocked() {
+		return
+	}
+
+	p.circuitOpenUntil = time.Now().Add(cb.CoolDown)
+	if p.open == 0 {
+		for _, w := range p.waiters {
+			w.err = ErrCircuitOpen
+			w.conns <- nil
+		}
+		p.waiters = nil
+	}
+}
+
+// circuitOpenLocked returns true if the circuit breaker is open, p.mu must be held
+func (p *ConnectionPool) circuitOpenLocked() bool {
+	return p.Config.CircuitBreaker != nil && time.Now().Before(p.circuitOpenUntil)
+}
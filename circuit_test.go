@@ -0,0 +1,52 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerFailsGetsFastWhileTheDeviceIsDown(t *testing.T) {
+	var down int32 = 1
+	p, err := pool.NewPool(pool.Config{
+		Size:           1,
+		Lazy:           true,
+		RetryDuration:  time.Millisecond,
+		CircuitBreaker: &pool.CircuitBreaker{Failures: 3, CoolDown: 50 * time.Millisecond},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.LoadInt32(&down) == 1 {
+				return nil, errors.New("no route to host")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	// The waiting Get is failed as soon as the breaker trips
+	start := time.Now()
+	_, err = p.Get(10*time.Second, pool.GetOptions{})
+	require.Equal(t, pool.ErrCircuitOpen, err)
+	require.True(t, time.Since(start) < time.Second)
+
+	_, err = p.Get(10*time.Second, pool.GetOptions{})
+	require.Equal(t, pool.ErrCircuitOpen, err)
+
+	// Once the device is back the breaker closes after the next successful attempt
+	atomic.StoreInt32(&down, 0)
+	require.Eventually(t, func() bool {
+		c, err := p.Get(time.Second, pool.GetOptions{})
+		if err != nil {
+			return false
+		}
+		return p.Release(c, nil) == nil
+	}, time.Second, 5*time.Millisecond)
+	<-p.Close()
+}
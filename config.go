@@ -1,23 +1,603 @@
 package pool
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net"
+	"net/url"
 	"time"
 )
 
+// ErrInvalidConfig is returned by NewPool when the Config is not usable, the returned error
+// wraps ErrInvalidConfig with a description of which field is wrong
+var ErrInvalidConfig = errors.New("invalid config")
+
+// ReuseStrategy decides which idle connection Get hands out
+type ReuseStrategy int
+
+const (
+	// ReuseFIFO hands out the connection that has been idle the longest, spreading the load
+	// over every connection and keeping them all active
+	ReuseFIFO ReuseStrategy = iota
+
+	// ReuseLIFO hands out the most recently used connection, keeping a small set of busy
+	// connections so the rest can be closed by IdleTimeout
+	ReuseLIFO
+)
+
+// Balance decides how connections are spread across Config.Addresses
+type Balance int
+
+const (
+	// BalanceEven gives every address the same number of connections
+	BalanceEven Balance = iota
+
+	// BalanceHealth gives more of the connections to the addresses that connection attempts
+	// succeed most often and fastest on, see AddressHealth
+	BalanceHealth
+)
+
 // Config contains all of the configuration parameters for the connection pool
 type Config struct {
-	// Name is a friendly name associated with the pool, cab be useful for debugging
-	Name          string
-	
+	// Name is a friendly name associated with the pool, cab be useful for debugging. The pool's
+	// background goroutines carry it in a "pool" pprof label
+	Name string
+
+	// Address is the address of the network resource, it is used by DefaultNewConnection
+	// and is available to custom NewConnection functions through the config they are passed.
+	// DefaultNewConnection dials addresses starting with tls://, such as tls://bridge:8443,
+	// with TLS
+	Address string
+
+	// Addresses if set spreads the pool's connections evenly across several addresses, for
+	// example a device with more than one control port, instead of using Address. Each new
+	// connection is made to the address with the fewest connections, the counts are
+	// reported in Stats.Addresses
+	Addresses []string
+
+	// Balance decides how connections are spread across Addresses, the default is
+	// BalanceEven
+	Balance Balance
+
 	// Size is the number of connections to open
-	Size          int
-	
+	Size int
+
+	// Lazy if set to true means Init doesn't open any connections, instead they are opened
+	// when Get is called and there are no idle connections, until there are Size of them.
+	// Connections that are thrown away are not replaced until they are needed again
+	Lazy bool
+
+	// MaxSize if larger than Size lets the pool grow past Size when callers are waiting for
+	// a connection, up to MaxSize connections. Once load drops connections above Size are
+	// closed as they are released, as long as MinIdle connections are left idle
+	MaxSize int
+
+	// MinIdle is the number of idle connections the pool tries to keep ready, it creates
+	// new ones in the background when connections are checked out, up to MaxSize
+	MinIdle int
+
+	// StandbyCount is the number of extra connections the pool keeps open on top of Size,
+	// which aren't handed out but take the place of connections that are thrown away
+	// straight away, then are replaced themselves in the background. Lazy pools don't
+	// keep standby connections
+	StandbyCount int
+
+	// AutoScale if set resizes the pool automatically based on how long callers wait for
+	// connections and how many connections are in use, see AutoScaleConfig
+	AutoScale *AutoScaleConfig
+
+	// Reuse decides which idle connection is handed out next, the default is ReuseFIFO
+	Reuse ReuseStrategy
+
+	// IdleTimeout if greater than 0 closes connections that have been idle in the pool for
+	// longer than the timeout, replacements are created when they are next needed
+	IdleTimeout time.Duration
+
+	// MaxConnLifetime if greater than 0 is how long a connection can be kept open. Older
+	// connections are closed and replaced when they are released, or while they are idle
+	MaxConnLifetime time.Duration
+
+	// MaxConnUses if greater than 0 is how many times a connection can be checked out, it is
+	// closed and replaced when it is released for the last time
+	MaxConnUses int
+
 	// RetryDuration specifies how long the pool will wait to try to create a new connection
 	// if the previous new conneciton attempt failed
 	RetryDuration time.Duration
-	
+
+	// RetryBudget if set limits how many times a minute the whole pool retries failed
+	// connection attempts, see RetryBudget
+	RetryBudget *RetryBudget
+
+	// Backoff if set replaces the fixed RetryDuration with a delay that grows after every
+	// failed attempt, see Backoff
+	Backoff *Backoff
+
+	// InitConcurrency if greater than 0 limits how many connections the pool creates at
+	// once, so Init doesn't open Size connections to a small device all at the same time.
+	// The limit applies to every connection attempt, not just those made by Init
+	InitConcurrency int
+
+	// DialDelay is the minimum time between the start of two connection attempts, which
+	// staggers the connections created by Init
+	DialDelay time.Duration
+
+	// CheckHalfOpen makes Get, GetUntil and GetContext check whether the other end has closed
+	// a connection before handing it out, for example because the device rebooted, without
+	// sending anything. The check peeks at the socket without blocking so it is cheap, but
+	// it only works for connections that wrap a socket, such as *net.TCPConn, on unix systems
+	CheckHalfOpen bool
+
+	// CheckOnBorrow if set is called with each connection before Get, GetUntil or GetContext
+	// hand it out. If it returns an error the connection is thrown away and replaced, and the
+	// call waits for a different one, in the same way as GetOptions.Validate
+	CheckOnBorrow func(net.Conn) error
+
+	// CheckOnReturn if set is called with each connection released without an error. If it
+	// returns an error, for example because there is unread data left on the connection, it
+	// is treated as if the connection was released with that error
+	CheckOnReturn func(net.Conn) error
+
+	// OnAcquire if set is called with each connection once it has passed CheckOnBorrow and
+	// GetOptions.Validate, just before Get, GetUntil or GetContext hand it out, to put it in
+	// a known state, for example by clearing the device's prompt. If it returns an error the
+	// connection is thrown away and the call waits for a different one
+	OnAcquire func(net.Conn) error
+
+	// OnReturn if set is called with each connection released without an error, before
+	// CheckOnReturn, to reset it for the next caller, for example by flushing input that
+	// hasn't been read. If it returns an error it is treated as if the connection was
+	// released with that error
+	OnReturn func(net.Conn) error
+
+	// HealthCheckInterval if greater than 0 is how often the idle connections are checked
+	// with Ping, so dead connections are replaced even when the pool isn't being used
+	HealthCheckInterval time.Duration
+
+	// Ping checks that an idle connection still works, returning an error if it doesn't.
+	// Connections are taken out of the pool while they are checked, ones that pass are put
+	// back and count as freshly used so they aren't closed by IdleTimeout
+	Ping func(net.Conn) error
+
+	// HeartbeatInterval if greater than 0 is how often the pool sends a heartbeat on every
+	// idle connection, for devices that drop sessions which have no traffic. Connections the
+	// heartbeat fails on are replaced
+	HeartbeatInterval time.Duration
+
+	// HeartbeatPayload is written to the connection as the heartbeat
+	HeartbeatPayload []byte
+
+	// Heartbeat if set is called to send the heartbeat instead of writing HeartbeatPayload,
+	// for protocols that need to read a reply
+	Heartbeat func(net.Conn) error
+
+	// MonitorInterval if greater than 0 is how often the pool checks it has Size working
+	// connections, creating any that are missing, for example because the pool gave up on
+	// them after MaxDialAttempts. If CheckHalfOpen is set idle connections are checked too,
+	// so ones that die while idle are replaced. Lazy pools aren't monitored
+	MonitorInterval time.Duration
+
+	// NetworkCheckInterval if greater than 0 is how often the pool checks the host's network
+	// interfaces. When an interface goes down or up or its addresses change every connection
+	// is closed and replaced, since sockets opened over the old interface won't work any more
+	NetworkCheckInterval time.Duration
+
+	// NetworkState returns a description of the host's network that changes whenever the
+	// connections need to be replaced, it is checked each NetworkCheckInterval. If it is nil
+	// the interfaces that are up and their addresses are used
+	NetworkState func() (string, error)
+
+	// ReconnectJitter if greater than 0 delays each attempt to replace a lost connection by a
+	// random time up to the jitter, so when every connection is lost at once, for example
+	// because the device rebooted, it isn't sent Size connection requests at the same time.
+	// Combine it with InitConcurrency to also cap how many are made at once
+	ReconnectJitter time.Duration
+
+	// DNSCacheTTL if greater than 0 makes the pool look up the host name in Address itself and
+	// pass NewConnection the resolved address, reusing the result for new connections for up
+	// to the TTL. A failed connection attempt drops the cached result so a device that has
+	// moved to a new address is found on the next attempt. By default the address is passed
+	// to NewConnection unchanged, so it is resolved again on every dial
+	DNSCacheTTL time.Duration
+
+	// LookupHost is used to look up the host name when DNSCacheTTL is set, if it is nil
+	// net.DefaultResolver.LookupHost is used
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+
 	// NewConnection takes in the pool config information and returns an open net.Conn connection
-	// that can be added to the pool.
+	// that can be added to the pool. If it is nil and Address is set, DefaultNewConnection is used
 	NewConnection func(Config) (net.Conn, error)
+
+	// DialTimeout if greater than 0 limits how long DefaultNewConnection takes to connect,
+	// including any proxy and TLS handshakes. Without it an unreachable device is given up
+	// on only when the operating system's connect timeout, often minutes, runs out
+	DialTimeout time.Duration
+
+	// KeepAlive is how often DefaultNewConnection's connections send TCP keep-alive probes
+	// while idle, as for net.Dialer.KeepAlive. If it is 0 the default of 15 seconds is used,
+	// if it is negative keep-alives are turned off
+	KeepAlive time.Duration
+
+	// LocalAddr if set is the local IP address, optionally with a port, DefaultNewConnection
+	// connects from, for hosts with a separate interface on the devices' network
+	LocalAddr string
+
+	// FallbackDelay is how long DefaultNewConnection waits for an IPv6 connection before also
+	// trying IPv4, for host names with both kinds of address, as for
+	// net.Dialer.FallbackDelay. If it is 0 the default of 300ms is used, if it is negative
+	// the addresses are tried one at a time
+	FallbackDelay time.Duration
+
+	// TCPDelay if true turns Nagle's algorithm on for the pool's TCP connections, so small
+	// writes are batched into fewer packets. It is off by default, TCP_NODELAY, which
+	// latency-sensitive control such as lighting needs. The TCP settings are applied to
+	// every new connection, from DefaultNewConnection or a custom NewConnection, that is or
+	// wraps a *net.TCPConn
+	TCPDelay bool
+
+	// TCPSendBuffer and TCPReceiveBuffer if greater than 0 set the size of the socket's send
+	// and receive buffers, SO_SNDBUF and SO_RCVBUF, in bytes
+	TCPSendBuffer    int
+	TCPReceiveBuffer int
+
+	// KeepAliveInterval and KeepAliveCount if greater than 0 set how often TCP keep-alive
+	// probes are sent once one goes unanswered and how many can go unanswered before the
+	// connection is dropped, so a device that has lost power is noticed sooner than with
+	// the operating system's defaults. The probes start after KeepAlive
+	KeepAliveInterval time.Duration
+	KeepAliveCount    int
+
+	// Proxy if set returns the proxy DefaultNewConnection connects to each address through,
+	// or nil to connect directly, for example to reach devices on an isolated network
+	// through a bastion. socks5://, socks5h:// and http:// proxies are supported, with a user
+	// name and password in the URL if the proxy needs them. See ProxyURL and
+	// ProxyFromEnvironment
+	Proxy func(addr string) (*url.URL, error)
+
+	// TLS if set makes DefaultNewConnection use TLS for every address, not only those
+	// starting with tls://, and configures the certificate checks. It is available to custom
+	// NewConnection functions too
+	TLS *TLSConfig
+
+	// WrapConn are applied in order to every new connection, each wrapping the result of the
+	// one before, so the last is the outermost. They can add layers such as a logging tap,
+	// byte counters, rate limiting or message framing without changing NewConnection. The
+	// wrapped connection is the one passed to the other hooks and handed out by the pool
+	WrapConn []func(net.Conn) net.Conn
+
+	// ReadTimeout and WriteTimeout if greater than 0 limit how long each Read and Write on
+	// the pool's connections can take, so callers talking to a device that has stalled get
+	// a timeout error rather than hanging forever, see DeadlineConn. The deadline is applied
+	// outside any WrapConn wrappers
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// WriteRateLimit if greater than 0 is the most writes a second made on each connection,
+	// with bursts of up to WriteBurst writes, see RateLimitConn. Writes over the limit wait
+	// for their turn before the WriteTimeout starts. Many cheap controllers stop responding
+	// if commands arrive faster than about 10 a second
+	WriteRateLimit float64
+	WriteBurst     int
+
+	// ReadBufferSize if greater than 0 gives each connection a bufio.Reader of that size
+	// which is kept for as long as the connection is open, see Connection.Reader, so line
+	// based protocols don't lose data buffered by one caller when the next one checks the
+	// connection out. Connections released with unread data in the buffer are thrown away
+	ReadBufferSize int
+
+	// OnConnect if set is called with every new connection before it is added to the pool,
+	// including ones made in the background to replace others, to run a login sequence,
+	// read a protocol banner or send subscription commands. If it returns an error the
+	// connection is closed and the attempt counts as a failed connection attempt, so it is
+	// retried like any other
+	OnConnect func(net.Conn) error
+
+	// OnClose if set is called with each of the pool's connections just before the pool
+	// closes it, for example to send a logout message, and the reason it is being closed:
+	// "bad connection", "idle timeout", "retired", "surplus", "failed health check",
+	// "replaced", "lease expired", "address changed", "network changed", "drained" or
+	// "pool closed". It is called from the goroutine closing the connection. Connections
+	// force closed while still checked out, by CloseContext or Drain, aren't passed to it
+	// since their callers may still be using them
+	OnClose func(c net.Conn, reason string)
+
+	// MaxDialAttempts if greater than 0 is how many times the pool tries to create each
+	// connection before giving up, by default it keeps trying until it succeeds. Once it gives
+	// up Init and any Get calls that can't be served report an error wrapping ErrDialFailed,
+	// the next Get that needs a connection starts trying again
+	MaxDialAttempts int
+
+	// CircuitBreaker if set makes Get calls fail straight away with ErrCircuitOpen, rather than
+	// waiting for their timeout, once connection attempts keep failing, see CircuitBreaker
+	CircuitBreaker *CircuitBreaker
+
+	// Quarantine if set stops the pool connecting to an address for a while once its
+	// connections keep going bad, see Quarantine
+	Quarantine *Quarantine
+
+	// Failover if set moves the pool to backup addresses while it can't connect to Address,
+	// see Failover
+	Failover *Failover
+
+	// RebootDetection if set treats every connection going bad at about the same time as the
+	// device having rebooted, see RebootDetection
+	RebootDetection *RebootDetection
+
+	// OnDialError if set is called every time an attempt to create a connection fails, with
+	// the error from NewConnection and the number of attempts made so far for that connection.
+	// It is called from the goroutine making the attempt
+	OnDialError func(attempt int, err error)
+
+	// MarkBadOnIOError makes a connection mark itself as bad when a Read or Write on it fails
+	// with an error IsBadConn reports as a connection error, including timeouts, so callers
+	// don't have to remember to release it with the error
+	MarkBadOnIOError bool
+
+	// LeakTimeout if greater than 0 reports connections that have been checked out for longer
+	// than the timeout, with the stack of the code that checked them out, to help find code
+	// that forgets to release them. Capturing the stack makes every Get slower so it is
+	// best used while debugging
+	LeakTimeout time.Duration
+
+	// OnLeak is called for each leaked connection, by default the leak is logged with Logger,
+	// or the standard logger if Logger isn't set
+	OnLeak func(Leak)
+
+	// MaxLeaseDuration if greater than 0 is the longest a connection can be checked out.
+	// Connections held for longer are closed and replaced so a hung caller can't keep one
+	// forever, the caller's reads and writes then fail and releasing it returns
+	// ErrLeaseExpired
+	MaxLeaseDuration time.Duration
+
+	// StallThreshold if greater than 0 turns on a watchdog that reports callers that have
+	// been waiting for longer than the threshold even though there are enough idle
+	// connections to serve them, either because of a bug or because they are queued behind
+	// a caller that needs more connections than are idle
+	StallThreshold time.Duration
+
+	// OnStall is called for each stalled caller, by default the stall is logged with Logger,
+	// or the standard logger if Logger isn't set
+	OnStall func(Stall)
+
+	// AuditSize if greater than 0 keeps a record of that many of the most recent Get and
+	// Release calls, with where they were called from and how long they waited or held the
+	// connection, which Dump returns. It is meant for working out afterwards what exhausted
+	// the pool
+	AuditSize int
+
+	// OnGet if set is called every time Get, GetContext or GetUntil gets a connection, with
+	// how long the call waited and whether the connection had to be created for it. It is
+	// called from the caller's goroutine before the connection is returned
+	OnGet func(waited time.Duration, dialed bool)
+
+	// OnRelease if set is called every time a connection is released, with how long it was
+	// checked out for and whether it was thrown away because it was released with an error
+	// or marked as bad. It is called from the goroutine releasing the connection
+	OnRelease func(held time.Duration, bad bool)
+
+	// OnConnStateChange if set is called every time one of the pool's connections changes
+	// state, with its Connection.ID. A new connection goes from StateDialing to
+	// StateEstablished, then between StateIdle and StateInUse as it is checked out and
+	// released, possibly to StateBad, and finally to StateClosed. The calls are made in order,
+	// shortly after each change, from one of the pool's goroutines
+	OnConnStateChange func(id uint64, from, to ConnState)
+
+	// Logger if set is used to log connection attempts, connections being thrown away and Get
+	// calls timing out, with the pool's Name and the address attached. By default the pool
+	// doesn't log them. Leaks, stalls and callbacks that panic are logged with it too, or with
+	// the standard logger if it isn't set
+	Logger *slog.Logger
+
+	// SlowGetThreshold if greater than 0 logs a warning, with the pool's current stats, for
+	// every Get that waits longer than the threshold for its connection, a sign the pool is
+	// too small. It is logged with Logger, or slog's default logger if Logger isn't set
+	SlowGetThreshold time.Duration
+
+	// ContextAttrs if set extracts attributes, such as a request ID or the name of the
+	// automation making the call, from the context passed to GetContext. They are added to
+	// the call's log messages and PoolExhausted events so the pool's activity can be matched
+	// up with the application's traces. The context is also passed on to Logger
+	ContextAttrs func(context.Context) []slog.Attr
+
+	// Metrics if set is sent the pool's counters, gauges and timings as they change, see
+	// MetricsSink
+	Metrics MetricsSink
+
+	// Expvar if set publishes the pool's Stats with the expvar package, in the ExpvarName
+	// map under the pool's Name, so they are served on /debug/vars along with the rest of
+	// the program's variables. The stats are removed when the pool is closed
+	Expvar bool
+
+	// LatencyBuckets are the upper bounds, in increasing order, of the buckets used for the
+	// Stats.WaitTimes and Stats.DialTimes histograms, defaults to DefaultLatencyBuckets
+	LatencyBuckets []time.Duration
+
+	// LifetimeBuckets are the upper bounds, in increasing order, of the buckets used for the
+	// Stats.Lifetimes histogram, defaults to DefaultLifetimeBuckets. The distribution helps
+	// pick IdleTimeout and MaxConnLifetime to suit how long a device keeps connections up
+	LifetimeBuckets []time.Duration
+
+	// ExecAttempts is the maximum number of times Exec will call its callback when the
+	// callback keeps failing with I/O errors, defaults to DefaultExecAttempts
+	ExecAttempts int
+
+	// IsBadConn is used by ReleaseWithError and Exec to decide if an error means the connection
+	// is broken and should be thrown away rather than reused, defaults to IsConnError
+	IsBadConn func(error) bool
+
+	// IsFatal reports errors that mean the address can't be used at all rather than that the
+	// attempt should be tried again later, for example a device rejecting the pool's
+	// credentials. A connection attempt that fails with a fatal error isn't retried, with
+	// Failover set the pool moves to the next address straight away instead. A fatal error
+	// passed to Release, ReleaseWithError or Exec always throws the connection away and with
+	// Failover set moves the pool to the next address. By default no errors are fatal
+	IsFatal func(error) bool
+}
+
+// Validate checks that the config can be used to create a pool, returning an error
+// wrapping ErrInvalidConfig describing the first problem found
+func (c Config) Validate() error {
+	if c.Size <= 0 {
+		return fmt.Errorf("%w: Size must be greater than 0, got %d", ErrInvalidConfig, c.Size)
+	}
+	if c.MaxSize != 0 && c.MaxSize < c.Size {
+		return fmt.Errorf("%w: MaxSize must not be less than Size, got %d", ErrInvalidConfig, c.MaxSize)
+	}
+	if c.MinIdle < 0 || (c.MinIdle > c.Size && c.MinIdle > c.MaxSize) {
+		return fmt.Errorf("%w: MinIdle must be between 0 and the pool size, got %d", ErrInvalidConfig, c.MinIdle)
+	}
+	if c.StandbyCount < 0 {
+		return fmt.Errorf("%w: StandbyCount must not be negative, got %d", ErrInvalidConfig, c.StandbyCount)
+	}
+	if c.AutoScale != nil {
+		if err := c.AutoScale.validate(); err != nil {
+			return fmt.Errorf("%w: AutoScale: %s", ErrInvalidConfig, err)
+		}
+	}
+	if c.Reuse != ReuseFIFO && c.Reuse != ReuseLIFO {
+		return fmt.Errorf("%w: unknown Reuse strategy %d", ErrInvalidConfig, c.Reuse)
+	}
+	if c.IdleTimeout < 0 {
+		return fmt.Errorf("%w: IdleTimeout must not be negative, got %s", ErrInvalidConfig, c.IdleTimeout)
+	}
+	if c.MaxConnLifetime < 0 {
+		return fmt.Errorf("%w: MaxConnLifetime must not be negative, got %s", ErrInvalidConfig, c.MaxConnLifetime)
+	}
+	if c.MaxConnUses < 0 {
+		return fmt.Errorf("%w: MaxConnUses must not be negative, got %d", ErrInvalidConfig, c.MaxConnUses)
+	}
+	if c.InitConcurrency < 0 {
+		return fmt.Errorf("%w: InitConcurrency must not be negative, got %d", ErrInvalidConfig, c.InitConcurrency)
+	}
+	if c.DialDelay < 0 {
+		return fmt.Errorf("%w: DialDelay must not be negative, got %s", ErrInvalidConfig, c.DialDelay)
+	}
+	if c.MaxDialAttempts < 0 {
+		return fmt.Errorf("%w: MaxDialAttempts must not be negative, got %d", ErrInvalidConfig, c.MaxDialAttempts)
+	}
+	if c.Backoff != nil {
+		if err := c.Backoff.validate(); err != nil {
+			return fmt.Errorf("%w: Backoff: %s", ErrInvalidConfig, err)
+		}
+	}
+	if c.HealthCheckInterval < 0 {
+		return fmt.Errorf("%w: HealthCheckInterval must not be negative, got %s", ErrInvalidConfig, c.HealthCheckInterval)
+	}
+	if c.HealthCheckInterval > 0 && c.Ping == nil {
+		return fmt.Errorf("%w: HealthCheckInterval needs Ping to be set", ErrInvalidConfig)
+	}
+	if c.HeartbeatInterval < 0 {
+		return fmt.Errorf("%w: HeartbeatInterval must not be negative, got %s", ErrInvalidConfig, c.HeartbeatInterval)
+	}
+	if c.HeartbeatInterval > 0 && c.Heartbeat == nil && len(c.HeartbeatPayload) == 0 {
+		return fmt.Errorf("%w: HeartbeatInterval needs HeartbeatPayload or Heartbeat to be set", ErrInvalidConfig)
+	}
+	if c.CircuitBreaker != nil {
+		if err := c.CircuitBreaker.validate(); err != nil {
+			return fmt.Errorf("%w: CircuitBreaker: %s", ErrInvalidConfig, err)
+		}
+	}
+	if c.Quarantine != nil {
+		if err := c.Quarantine.validate(); err != nil {
+			return fmt.Errorf("%w: Quarantine: %s", ErrInvalidConfig, err)
+		}
+	}
+	for _, addr := range c.Addresses {
+		if addr == "" {
+			return fmt.Errorf("%w: Addresses must not contain an empty address", ErrInvalidConfig)
+		}
+	}
+	for _, wrap := range c.WrapConn {
+		if wrap == nil {
+			return fmt.Errorf("%w: WrapConn must not contain nil functions", ErrInvalidConfig)
+		}
+	}
+	if c.Balance != BalanceEven && c.Balance != BalanceHealth {
+		return fmt.Errorf("%w: unknown Balance %d", ErrInvalidConfig, c.Balance)
+	}
+	if c.Expvar && c.Name == "" {
+		return fmt.Errorf("%w: Name must be set to publish the stats with Expvar", ErrInvalidConfig)
+	}
+	if err := validateBuckets(c.LatencyBuckets); err != nil {
+		return fmt.Errorf("%w: LatencyBuckets %s", ErrInvalidConfig, err)
+	}
+	if err := validateBuckets(c.LifetimeBuckets); err != nil {
+		return fmt.Errorf("%w: LifetimeBuckets %s", ErrInvalidConfig, err)
+	}
+	if c.Failover != nil && len(c.Addresses) > 0 {
+		return fmt.Errorf("%w: Failover can't be used with Addresses", ErrInvalidConfig)
+	}
+	if c.Failover != nil {
+		if err := c.Failover.validate(); err != nil {
+			return fmt.Errorf("%w: Failover: %s", ErrInvalidConfig, err)
+		}
+	}
+	if c.MonitorInterval < 0 {
+		return fmt.Errorf("%w: MonitorInterval must not be negative, got %s", ErrInvalidConfig, c.MonitorInterval)
+	}
+	if c.RebootDetection != nil && c.RebootDetection.Window <= 0 {
+		return fmt.Errorf("%w: RebootDetection: Window must be greater than 0", ErrInvalidConfig)
+	}
+	if c.LeakTimeout < 0 {
+		return fmt.Errorf("%w: LeakTimeout must not be negative, got %s", ErrInvalidConfig, c.LeakTimeout)
+	}
+	if c.MaxLeaseDuration < 0 {
+		return fmt.Errorf("%w: MaxLeaseDuration must not be negative, got %s", ErrInvalidConfig, c.MaxLeaseDuration)
+	}
+	if c.WriteRateLimit < 0 || c.WriteBurst < 0 {
+		return fmt.Errorf("%w: WriteRateLimit and WriteBurst must not be negative", ErrInvalidConfig)
+	}
+	if c.ReadBufferSize < 0 {
+		return fmt.Errorf("%w: ReadBufferSize must not be negative, got %d", ErrInvalidConfig, c.ReadBufferSize)
+	}
+	if c.DialTimeout < 0 {
+		return fmt.Errorf("%w: DialTimeout must not be negative, got %s", ErrInvalidConfig, c.DialTimeout)
+	}
+	if c.TCPSendBuffer < 0 || c.TCPReceiveBuffer < 0 {
+		return fmt.Errorf("%w: TCPSendBuffer and TCPReceiveBuffer must not be negative", ErrInvalidConfig)
+	}
+	if c.KeepAliveInterval < 0 || c.KeepAliveCount < 0 {
+		return fmt.Errorf("%w: KeepAliveInterval and KeepAliveCount must not be negative", ErrInvalidConfig)
+	}
+	if c.KeepAlive < 0 && (c.KeepAliveInterval > 0 || c.KeepAliveCount > 0) {
+		return fmt.Errorf("%w: KeepAliveInterval and KeepAliveCount can't be used with keep-alives turned off", ErrInvalidConfig)
+	}
+	if c.LocalAddr != "" {
+		if _, err := localAddr(c.LocalAddr); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidConfig, err)
+		}
+	}
+	if c.ReadTimeout < 0 || c.WriteTimeout < 0 {
+		return fmt.Errorf("%w: ReadTimeout and WriteTimeout must not be negative", ErrInvalidConfig)
+	}
+	if c.SlowGetThreshold < 0 {
+		return fmt.Errorf("%w: SlowGetThreshold must not be negative, got %s", ErrInvalidConfig, c.SlowGetThreshold)
+	}
+	if c.AuditSize < 0 {
+		return fmt.Errorf("%w: AuditSize must not be negative, got %d", ErrInvalidConfig, c.AuditSize)
+	}
+	if c.StallThreshold < 0 {
+		return fmt.Errorf("%w: StallThreshold must not be negative, got %s", ErrInvalidConfig, c.StallThreshold)
+	}
+	if c.RetryBudget != nil && (c.RetryBudget.PerMinute <= 0 || c.RetryBudget.Burst <= 0) {
+		return fmt.Errorf("%w: RetryBudget: PerMinute and Burst must be greater than 0", ErrInvalidConfig)
+	}
+	if c.NetworkCheckInterval < 0 {
+		return fmt.Errorf("%w: NetworkCheckInterval must not be negative, got %s", ErrInvalidConfig, c.NetworkCheckInterval)
+	}
+	if c.DNSCacheTTL < 0 {
+		return fmt.Errorf("%w: DNSCacheTTL must not be negative, got %s", ErrInvalidConfig, c.DNSCacheTTL)
+	}
+	if c.ReconnectJitter < 0 {
+		return fmt.Errorf("%w: ReconnectJitter must not be negative, got %s", ErrInvalidConfig, c.ReconnectJitter)
+	}
+	if c.RetryDuration < 0 {
+		return fmt.Errorf("%w: RetryDuration must not be negative, got %s", ErrInvalidConfig, c.RetryDuration)
+	}
+	if c.NewConnection == nil && c.Address == "" && len(c.Addresses) == 0 {
+		return fmt.Errorf("%w: one of NewConnection, Address or Addresses must be set", ErrInvalidConfig)
+	}
+	return nil
 }
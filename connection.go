@@ -1,31 +1,245 @@
 package pool
 
-import "net"
+import (
+	"bufio"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+)
 
-// Connection represents a connection to a network resource
+// errDiscarded is the error the connection is released with when Discard is called
+var errDiscarded = errors.New("connection discarded")
+
+// pooledConn is one of the pool's underlying connections. It lives for as long as the
+// connection is open, a new Connection lease is handed out for it every time it is
+// checked out of the pool
+type pooledConn struct {
+	net.Conn
+
+	// reader is the connection's buffered reader if Config.ReadBufferSize is set
+	reader *bufio.Reader
+
+	// owner is the pool the connection belongs to, nil if it was made by NewConnection, and
+	// state is the state last passed to Config.OnConnStateChange, protected by the owner's
+	// changes mutex
+	owner *ConnectionPool
+	state ConnState
+
+	// id identifies the connection for as long as the pool exists and attempts is how many
+	// attempts it took to create it
+	id       uint64
+	attempts int
+
+	// bad is set to 1 once the connection has been marked as bad, closed once the
+	// underlying connection has been closed
+	bad    int32
+	closed int32
+
+	// createdAt is when the pool added the connection, lastUsed the last time it was
+	// checked out and idleSince the last time it was put back on the idle list. All of
+	// them are protected by the owning pool's mutex
+	createdAt time.Time
+	lastUsed  time.Time
+	idleSince time.Time
+
+	// addr is the address the connection was made to
+	addr string
+
+	// uses is how many times the connection has been checked out, protected by the
+	// owning pool's mutex
+	uses int
+
+	// bytesRead and bytesWritten count the bytes passed through the Connection leases and
+	// errors the reads and writes that failed and the times the connection was released
+	// with an error, they are updated atomically
+	bytesRead    int64
+	bytesWritten int64
+	errors       int64
+}
+
+// setBad flags the connection as bad, returning true if it wasn't already
+func (pc *pooledConn) setBad() bool {
+	if !atomic.CompareAndSwapInt32(&pc.bad, 0, 1) {
+		return false
+	}
+	pc.setState(StateBad)
+	return true
+}
+
+func (pc *pooledConn) isHealthy() bool {
+	return atomic.LoadInt32(&pc.bad) == 0
+}
+
+func (pc *pooledConn) isClosed() bool {
+	return atomic.LoadInt32(&pc.closed) == 1
+}
+
+// destroy closes the underlying connection
+func (pc *pooledConn) destroy() error {
+	atomic.StoreInt32(&pc.closed, 1)
+	pc.setState(StateClosed)
+	if pc.Conn != nil {
+		return pc.Conn.Close()
+	}
+	return nil
+}
+
+// Connection represents a connection to a network resource. It is a lease on one of the
+// pool's connections, a new one is returned every time the connection is checked out.
+// Calling Close returns it to the pool rather than closing it so it can be handed to
+// code that expects to own a net.Conn
 type Connection struct {
 	net.Conn
-	owner         *ConnectionPool
-	returnOnClose bool
+	owner Pooler
+	pc    *pooledConn
+
+	// stack is where the lease was checked out and leaked is set once it has been reported
+	// as leaked, see Config.LeakTimeout. reclaimed is set if the pool took the connection
+	// back after Config.MaxLeaseDuration. All are protected by the owning pool's mutex
+	stack     []byte
+	leaked    bool
+	reclaimed bool
+
+	// afterRelease is called once the lease has been released, see AfterRelease
+	afterRelease func(error)
 }
 
-// NewConnection returns an initialized Connection instance
-func NewConnection(c net.Conn, p *ConnectionPool) *Connection {
+// NewConnection returns an initialized Connection instance that is released back to owner
+// when it is closed. Other Pooler implementations can use it to hand out connections, if
+// owner is nil closing the connection closes c
+func NewConnection(c net.Conn, owner Pooler) *Connection {
 	return &Connection{
-		Conn:          c,
-		owner:         p,
-		returnOnClose: true,
+		Conn:  c,
+		owner: owner,
+		pc:    &pooledConn{Conn: c},
 	}
 }
 
-// Close returns the connection to the pool, the connection stays open
+// Close returns the connection to the pool, the connection stays open. ErrAlreadyReleased
+// is returned if the connection has already been returned
 func (c *Connection) Close() error {
-	if !c.returnOnClose {
-		if c.Conn != nil {
-			return c.Conn.Close()
-		}
-		return nil
+	if c.owner == nil {
+		return c.pc.destroy()
 	}
-	c.owner.Release(c, nil)
-	return nil
+	return c.owner.Release(c, nil)
+}
+
+// AfterRelease sets fn to be called once the lease has been released, whether by Release,
+// Close or Discard, with the error it was released with. It isn't called if releasing fails,
+// for example because the lease was already released or taken back after
+// Config.MaxLeaseDuration. It is intended for wrappers, such as the otelpool package, that
+// need to know when a lease ends however it is given back
+func (c *Connection) AfterRelease(fn func(err error)) {
+	c.afterRelease = fn
+}
+
+// afterRelease calls the AfterRelease hook of c if there is one
+func (p *ConnectionPool) afterRelease(c *Connection, err error) {
+	if c.afterRelease != nil {
+		p.safely("AfterRelease", func() { c.afterRelease(err) })
+	}
+}
+
+// Discard closes the underlying connection instead of returning it to the pool, the pool
+// then creates a new connection to replace it. Use this when the connection is known to
+// be in a bad state
+func (c *Connection) Discard() error {
+	if c.owner == nil {
+		return c.pc.destroy()
+	}
+	return c.owner.Release(c, errDiscarded)
+}
+
+// MarkBad flags the connection as broken. The pool starts creating a replacement straight
+// away and the connection is closed rather than reused once it is released. Until then the
+// pool has more than Size connections open, so release bad connections promptly when
+// talking to devices with a low connection limit. Marking a lease that has already been
+// released has no effect. It is safe to call MarkBad more than once and from multiple
+// goroutines
+func (c *Connection) MarkBad() {
+	if p, ok := c.owner.(*ConnectionPool); ok {
+		p.markBad(c)
+		return
+	}
+	c.pc.setBad()
+}
+
+// Read reads from the connection, through its Reader if it has one. If the pool has
+// Config.MarkBadOnIOError set and the read fails with a connection error the connection is
+// marked as bad
+func (c *Connection) Read(b []byte) (int, error) {
+	if c.pc.reader != nil {
+		return c.pc.reader.Read(b)
+	}
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.pc.bytesRead, int64(n))
+	if err != nil {
+		atomic.AddInt64(&c.pc.errors, 1)
+		c.checkIOError(err)
+	}
+	return n, err
+}
+
+// Write writes to the connection. If the pool has Config.MarkBadOnIOError set and the write
+// fails with a connection error the connection is marked as bad
+func (c *Connection) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.pc.bytesWritten, int64(n))
+	if err != nil {
+		atomic.AddInt64(&c.pc.errors, 1)
+		c.checkIOError(err)
+	}
+	return n, err
+}
+
+// checkIOError marks the connection as bad if the error means it is broken and the pool is
+// configured to do so
+func (c *Connection) checkIOError(err error) {
+	if p, ok := c.owner.(*ConnectionPool); ok && p.Config.MarkBadOnIOError && p.isBadConn(err) {
+		p.markBad(c)
+	}
+}
+
+// ID returns a number identifying the underlying connection, it stays the same across every
+// lease on the connection and is unique within the pool. It matches Event.ConnID and
+// ConnInfo.ID so callers' logs can be matched up with the pool's. Connections created with
+// NewConnection have an ID of 0
+func (c *Connection) ID() uint64 {
+	return c.pc.id
+}
+
+// DialAttempts returns how many attempts it took to create the underlying connection
+func (c *Connection) DialAttempts() int {
+	return c.pc.attempts
+}
+
+// CreatedAt returns when the underlying connection was added to the pool
+func (c *Connection) CreatedAt() time.Time {
+	unlock := c.lockOwner()
+	defer unlock()
+	return c.pc.createdAt
+}
+
+// LastUsedAt returns when the underlying connection was last checked out, which is when
+// this lease was handed out unless the lease has been released
+func (c *Connection) LastUsedAt() time.Time {
+	unlock := c.lockOwner()
+	defer unlock()
+	return c.pc.lastUsed
+}
+
+// lockOwner locks the owning pool's mutex, which protects the connection's timestamps, and
+// returns the function that unlocks it
+func (c *Connection) lockOwner() func() {
+	if p, ok := c.owner.(*ConnectionPool); ok {
+		p.mu.Lock()
+		return p.mu.Unlock
+	}
+	return func() {}
+}
+
+// IsHealthy returns false if the connection has been marked as bad
+func (c *Connection) IsHealthy() bool {
+	return c.pc.isHealthy()
 }
@@ -1,123 +1,1467 @@
 package pool
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"log/slog"
+	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // ErrTimeout represents a timeout error, for example you called Get and couldn't get
-// a connection within the timeout period.
+// a connection within the timeout period. The pool returns a *TimeoutError describing the
+// timeout, check for it with errors.Is(err, ErrTimeout)
 var ErrTimeout = errors.New("timeout")
 
+// TimeoutError is the error returned by Get, GetUntil and GetN when no connection became
+// available in time, it carries the state of the pool so the error can be acted on without
+// looking up its stats
+type TimeoutError struct {
+	// Pool is the pool's Config.Name and Size its size when the call gave up
+	Pool string
+	Size int
+
+	// Waiters is how many other callers were still waiting for a connection
+	Waiters int
+
+	// Waited is how long the call waited
+	Waited time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timeout: no connection from pool %q of size %d after %s, %d other callers waiting",
+		e.Pool, e.Size, e.Waited.Round(time.Millisecond), e.Waiters)
+}
+
+// Is reports whether target is ErrTimeout
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+// timeoutError returns the error for a call that gave up after waiting since start
+func (p *ConnectionPool) timeoutError(start time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return &TimeoutError{Pool: p.Config.Name, Size: p.size, Waiters: len(p.waiters), Waited: time.Since(start)}
+}
+
+// ErrPoolClosed is returned when trying to get a connection from a pool that has been closed
+var ErrPoolClosed = errors.New("pool closed")
+
+// ErrAlreadyReleased is returned when releasing a connection that has already been
+// returned to the pool
+var ErrAlreadyReleased = errors.New("connection already released")
+
+// ErrForeignConnection is returned when releasing a connection that was not created by the pool
+var ErrForeignConnection = errors.New("connection does not belong to this pool")
+
+// ErrExhausted is returned by TryGet when there are no idle connections in the pool
+var ErrExhausted = errors.New("pool exhausted")
+
+// ErrPaused is returned by TryGet, and Get calls with GetOptions.NoWait set, while the pool
+// is paused
+var ErrPaused = errors.New("pool is paused")
+
+// ErrDialFailed is wrapped by the errors returned from Init and Get when the pool has given up
+// trying to create a connection after Config.MaxDialAttempts attempts
+var ErrDialFailed = errors.New("failed to create a connection")
+
+// ErrDraining is returned when trying to get a connection while the pool is being drained
+var ErrDraining = errors.New("pool is draining")
+
 // ConnectionPool provides the ability to pool connections
 type ConnectionPool struct {
 	Config Config
-	pool   chan *Connection
-	closed bool
+
+	mu sync.Mutex
+
+	// size is the number of connections the pool maintains, it starts as Config.Size
+	// and is changed by Resize
+	size int
+
+	// address is the primary address new connections are made to, it starts as
+	// Config.Address and is changed by SetAddress
+	address string
+
+	// idle holds the connections that are available to be handed out, waiters is the
+	// queue of callers waiting for connections, served in the order they arrived
+	idle    []*pooledConn
+	waiters []*waiter
+
+	closed   bool
+	closing  chan struct{}
+	conns    map[*pooledConn]struct{}
+	inUse    map[*pooledConn]*Connection
+	released chan struct{}
+
+	// stop is closed when the pool is closed or starts draining, it stops the background
+	// workers and connection attempts started since the last Init. draining is true until
+	// a Drain has finished, then stop is replaced ready for the next Init
+	stop     chan struct{}
+	draining bool
+
+	// paused is true between calls to Pause and Resume, connections aren't handed out
+	// while it is set
+	paused bool
+
+	// dialFailures is the number of connection attempts in a row that have failed,
+	// circuitOpenUntil is when the circuit breaker closes again after it has tripped
+	dialFailures     int
+	circuitOpenUntil time.Time
+
+	// lastDialErr is the error from the last failed connection attempt
+	lastDialErr error
+
+	// badMarks holds when connections to each address were marked as bad during the current
+	// Config.Quarantine window, quarantined when each quarantined address is usable again
+	badMarks    map[string][]time.Time
+	quarantined map[string]time.Time
+
+	// rebootMarks holds when connections were marked as bad during the current
+	// Config.RebootDetection window, rebooting is true while the pool is reconnecting after
+	// a reboot
+	rebootMarks []time.Time
+	rebooting   bool
+
+	// quiescedUntil is when the current maintenance window started by Quiesce ends, zero
+	// if there isn't one
+	quiescedUntil time.Time
+
+	// resolved holds the cached lookups of the addresses the pool connects to when
+	// Config.DNSCacheTTL is set
+	resolved map[string]resolvedAddr
+
+	// failoverIndex is the position in the primary address followed by the Config.Failover
+	// addresses of the address new connections are made to, failoverFailures is the number
+	// of attempts in a row that have failed to connect to it. probing is true while
+	// probePrimary is running
+	failoverIndex    int
+	failoverFailures int
+	probing          bool
+
+	// dialing is the number of connection attempts in progress to each address, nextAddr is
+	// where pickLocked starts looking for the next address to use
+	dialing  map[string]int
+	nextAddr int
+
+	// health holds how connection attempts to each of Config.Addresses have gone
+	health map[string]AddressHealth
+
+	// open is the number of connections that have been created and not yet closed,
+	// changed is closed and replaced every time open changes. pending is the number
+	// of connections currently being created
+	open    int
+	changed chan struct{}
+	pending int
+
+	// dialSlots limits how many connections are created at once when Config.InitConcurrency
+	// is set, nextDial is the earliest the next attempt can start when Config.DialDelay is set
+	dialSlots chan struct{}
+	nextDial  time.Time
+
+	// retryTokens is how much of Config.RetryBudget is left, as of retryRefilled
+	retryTokens   float64
+	retryRefilled time.Time
+
+	// standby holds the connections kept ready to replace ones that are thrown away, they
+	// aren't counted as open. standbyPending is the number of them being created
+	standby        []*pooledConn
+	standbyPending int
+
+	// waitTotal and waitCount accumulate how long Get calls waited for a connection since
+	// the autoscaler last looked at them
+	waitTotal time.Duration
+	waitCount int
+
+	// dials, dialErrors, timeouts and waited are the running totals reported by Stats
+	dials      int64
+	dialErrors int64
+	timeouts   int64
+	waited     time.Duration
+
+	// waitTimes and dialTimes record how long Get calls waited and connection attempts took
+	waitTimes *Histogram
+	dialTimes *Histogram
+
+	// lifetimes records how long connections were open for before they were evicted
+	lifetimes *Histogram
+
+	// log is Config.Logger with the pool's name attached, nil if it isn't set
+	log *slog.Logger
+
+	// subs holds the channels events are sent to
+	subs subscribers
+
+	// releases records when connections were recently released, for EstimatedWait
+	releases releaseHistory
+
+	// changes holds the connection state changes waiting to be passed to
+	// Config.OnConnStateChange
+	changes stateChanges
+
+	// lastID is the ID given to the most recently created connection
+	lastID uint64
+
+	// auditRing holds the recent operations when Config.AuditSize is set, once it is full
+	// auditNext is the oldest entry, the next one to be overwritten
+	auditRing []AuditEntry
+	auditNext int
+
+	// initDone is closed once the first call to Init has finished, initResult holds
+	// the result which is handed to every caller
+	initDone   chan struct{}
+	initResult InitResult
+
+	// idleClosed is closed once the first call to Close has closed the idle connections
+	idleClosed chan struct{}
 }
 
 // NewPool creates a new ConnectionPool.  The pool which is returned will still need to
-// have Init() called in it before it can be used
-func NewPool(config Config) *ConnectionPool {
+// have Init() called in it before it can be used. An error wrapping ErrInvalidConfig is
+// returned if the config fails validation
+func NewPool(config Config) (*ConnectionPool, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.NewConnection == nil {
+		config.NewConnection = DefaultNewConnection
+	}
+	lifetimes := config.LifetimeBuckets
+	if lifetimes == nil {
+		lifetimes = DefaultLifetimeBuckets
+	}
+
 	p := &ConnectionPool{
-		Config: config,
-		pool:   make(chan *Connection, config.Size),
+		Config:  config,
+		size:    config.Size,
+		address: config.Address,
+		closing: make(chan struct{}),
+		stop:    make(chan struct{}),
+		conns:   make(map[*pooledConn]struct{}),
+		inUse:   make(map[*pooledConn]*Connection),
+		changed: make(chan struct{}),
+
+		waitTimes: newHistogram(config.LatencyBuckets),
+		dialTimes: newHistogram(config.LatencyBuckets),
+		lifetimes: newHistogram(lifetimes),
+		log:       newLogger(config),
 	}
-	return p
+	if config.InitConcurrency > 0 {
+		p.dialSlots = make(chan struct{}, config.InitConcurrency)
+	}
+	p.publish()
+	return p, nil
+}
+
+// InitResult is delivered on the channel returned by InitContext once initialization
+// has finished, either because all of the connections were created or because the
+// context was done first
+type InitResult struct {
+	// Established is the number of connections that were created during Init
+	Established int
+
+	// Err is the context error if initialization was aborted before all of the
+	// connections could be created, an error wrapping ErrDialFailed if the pool gave up
+	// on some of them after Config.MaxDialAttempts attempts, nil otherwise
+	Err error
+
+	// LastDialErr is the last error returned by Config.NewConnection during Init, nil if
+	// every attempt succeeded
+	LastDialErr error
 }
 
 // Init should be called before using the pool, the call is non blocking, but you
 // can wait on the returned channel if you want to know when all of the underlying
-// connections have been created and are ready to use
+// connections have been created and are ready to use. It is safe to call Init more
+// than once, later calls wait for the first initialization to complete
 func (p *ConnectionPool) Init() chan bool {
-
 	done := make(chan bool, 1)
-	var wg sync.WaitGroup
-	wg.Add(p.Config.Size)
+	result := p.InitContext(context.Background())
+	go func() {
+		<-result
+		done <- true
+	}()
+	return done
+}
+
+// InitContext is like Init but the pool stops trying to create connections once the context
+// is done. The returned channel receives an InitResult indicating how many connections were
+// established before initialization completed or was cancelled. If the pool has already been
+// initialized, the context is ignored and the result of the first initialization is returned.
+// Once a Drain has finished the pool can be initialized again
+func (p *ConnectionPool) InitContext(ctx context.Context) chan InitResult {
+	return p.init(ctx, nil)
+}
 
-	for i := 0; i < p.Config.Size; i++ {
-		p.retryNewConnection(&wg)
+// InitProgress describes how far initialization has got, see InitWithProgress
+type InitProgress struct {
+	// Established is the number of connections that have been created so far
+	Established int
+
+	// Failed is the number of connection attempts that have failed so far, failed
+	// attempts are retried so this can be larger than the pool size
+	Failed int
+
+	// Remaining is the number of connections that still need to be created
+	Remaining int
+}
+
+// InitWithProgress is like InitContext but also returns a channel that receives the
+// progress of initialization every time a connection attempt succeeds or fails, for example
+// to show how far a device is from being ready. Only the latest progress is kept if the
+// caller falls behind. The progress channel is closed once initialization has finished, or
+// straight away if the pool has already been initialized
+func (p *ConnectionPool) InitWithProgress(ctx context.Context) (chan InitResult, chan InitProgress) {
+	progress := make(chan InitProgress, 1)
+	return p.init(ctx, progress), progress
+}
+
+// init starts initialization the first time it is called after the pool was created or
+// drained, then returns a channel that receives the result once it has finished. progress
+// if not nil receives progress updates and is closed when initialization finishes
+func (p *ConnectionPool) init(ctx context.Context, progress chan InitProgress) chan InitResult {
+	p.mu.Lock()
+	if p.initDone == nil {
+		p.initDone = make(chan struct{})
+		p.startInit(ctx, progress)
+		p.startWorkers()
+	} else if progress != nil {
+		close(progress)
 	}
+	initDone := p.initDone
+	p.mu.Unlock()
 
 	// Return the channel to let the caller know when init has completed
+	done := make(chan InitResult, 1)
+	go func() {
+		<-initDone
+		p.mu.Lock()
+		result := p.initResult
+		p.mu.Unlock()
+		done <- result
+	}()
+	return done
+}
+
+// startInit starts creating all of the connections, closing initDone once finished. Lazy
+// pools only create MinIdle connections up front, in the background, so initialization
+// completes straight away. If progress is not nil it receives the latest InitProgress after
+// every attempt and is closed once finished.
+// p.mu must be held
+func (p *ConnectionPool) startInit(ctx context.Context, progress chan InitProgress) {
+	if p.Config.Lazy {
+		p.topUpLocked()
+		close(p.initDone)
+		if progress != nil {
+			close(progress)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	size, stop := p.size, p.stop
+	state := InitProgress{Remaining: size}
+	var lastErr error
+	wg.Add(size)
+
+	// publish replaces any progress the caller hasn't read yet with the latest, mu must be
+	// held so there is only ever one sender
+	publish := func() {
+		if progress == nil {
+			return
+		}
+		select {
+		case <-progress:
+		default:
+		}
+		progress <- state
+	}
+
+	for i := 0; i < size; i++ {
+		p.dialLocked(ctx, dialHooks{
+			failed: func(attempt int, err error) {
+				mu.Lock()
+				lastErr = err
+				state.Failed++
+				publish()
+				mu.Unlock()
+			},
+			done: func(ok bool) {
+				if ok {
+					mu.Lock()
+					state.Established++
+					state.Remaining--
+					publish()
+					mu.Unlock()
+				}
+				wg.Done()
+			},
+		})
+	}
+
+	p.topUpStandbyLocked()
+
 	go func() {
 		wg.Wait()
+		result := InitResult{Established: state.Established, LastDialErr: lastErr}
+		if result.Established < size {
+			result.Err = ctx.Err()
+			if result.Err == nil && !isDone(stop) && lastErr != nil {
+				result.Err = fmt.Errorf("%w: %v", ErrDialFailed, lastErr)
+			}
+		}
+		if progress != nil {
+			close(progress)
+		}
+
+		p.mu.Lock()
+		p.initResult = result
+		close(p.initDone)
+		p.mu.Unlock()
+	}()
+}
+
+// Close closes all of the idle connections, this is non blocking but you can wait on the
+// returned channel if you need to know all the idle connections have closed. Connections
+// that are currently checked out are closed when they are released back to the pool.
+// It is safe to call Close more than once, later calls wait for the first one to complete
+func (p *ConnectionPool) Close() chan bool {
+	done := make(chan bool, 1)
+	idleClosed, _ := p.shutdown()
+	p.unpublish()
+	go func() {
+		<-idleClosed
 		done <- true
 	}()
 	return done
 }
 
-// Close closes all of the underlying connections, this is non blocking but you can
-// wait on the returned channel if you need to know all the connections have closed
-func (p *ConnectionPool) Close() chan bool {
-	done := make(chan bool)
+// CloseContext closes all of the connections in the pool. Idle connections are closed
+// straight away, then the pool waits for all of the checked out connections to be released.
+// If the context is done before that happens, the connections that are still checked out
+// are forcibly closed. The call is non blocking, the returned channel receives a value once
+// every connection has been closed
+func (p *ConnectionPool) CloseContext(ctx context.Context) chan bool {
+	done := make(chan bool, 1)
+	idleClosed, released := p.shutdown()
+	p.unpublish()
 	go func() {
-		for len(p.pool) > 0 {
-			c := <-p.pool
-			c.returnOnClose = false
-			c.Close()
+		<-idleClosed
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			p.mu.Lock()
+			p.closeInUseLocked()
+			p.releasedLocked()
+			p.mu.Unlock()
 		}
 		done <- true
 	}()
 	return done
 }
 
+// shutdown marks the pool as closed and starts closing all of the idle connections, only the
+// first call has any effect. The idleClosed channel is closed once the idle connections have
+// been closed, released is closed once there are no more connections checked out of the pool
+func (p *ConnectionPool) shutdown() (idleClosed, released chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.closed {
+		p.closed = true
+		close(p.closing)
+		p.emit(Event{Type: PoolClosed})
+		if !p.draining {
+			close(p.stop)
+		}
+
+		p.released = make(chan struct{})
+		if len(p.inUse) == 0 {
+			p.releasedLocked()
+		}
+
+		p.idleClosed = make(chan struct{})
+		idle := p.idle
+		p.idle = nil
+		standby := p.takeStandbyLocked()
+		idleClosed := p.idleClosed
+		p.goLabeled("close", func() {
+			for _, pc := range idle {
+				p.discard(pc, closePoolClosed)
+			}
+			for _, pc := range standby {
+				p.closeConn(pc, closePoolClosed)
+			}
+			close(idleClosed)
+		})
+	}
+	return p.idleClosed, p.released
+}
+
+// closeInUseLocked forcibly closes every connection that is checked out of the pool, p.mu must
+// be held
+func (p *ConnectionPool) closeInUseLocked() {
+	for pc := range p.inUse {
+		delete(p.inUse, pc)
+		delete(p.conns, pc)
+		pc.destroy()
+		p.open--
+	}
+	p.notifyLocked()
+}
+
+// waiter is a caller blocked waiting for n connections, once they are available the pool
+// marks them as in use and sends them on conns. If the pool gives up on the waiter it sets
+// err then sends nil instead
+type waiter struct {
+	n        int
+	priority int
+	conns    chan []*Connection
+	err      error
+
+	// since is when the waiter was queued, stalled is set once the watchdog has reported it
+	since   time.Time
+	stalled bool
+}
+
+// GetOptions controls the behaviour of a single call to Get, GetUntil or GetContext. The
+// zero value waits for a connection with normal priority and hands it out as is
+type GetOptions struct {
+	// Flush if set to true will read all of the outstanding data from the connection before
+	// returning it to the caller. Note there is a possible 100ms delay for the call to return
+	// while the pool tries to read any existing content from the connection
+	Flush bool
+
+	// NoWait makes the call return ErrExhausted straight away if there are no idle
+	// connections, instead of waiting for one to be released
+	NoWait bool
+
+	// Priority orders callers waiting for a connection, callers with a higher priority are
+	// handed connections first. Callers with the same priority are served in arrival order
+	Priority int
+
+	// Validate if set is called with the connection before it is handed out. If it returns
+	// an error the connection is thrown away and the call waits for a different one
+	Validate func(net.Conn) error
+
+	// Logf if set is called to log the progress of the call, useful for debugging
+	Logf func(format string, args ...interface{})
+
+	// Trace if set holds hooks called during the call, as well as any attached to the
+	// context with WithGetTrace, so calls without a context, such as Get, can be traced too
+	Trace *GetTrace
+}
+
+func (o GetOptions) logf(format string, args ...interface{}) {
+	if o.Logf != nil {
+		o.Logf(format, args...)
+	}
+}
+
 // Get is a blocking function that waits to get an available connection.  If after the
 // timeout duration a connection could not be fetched, the function returns with ErrTimeout.
-// The flush parameter if set to true will read all of the outstanding data from the
-// connection before returning it to the caller. Note there is a possible 100ms delay for this
-// function to return if you set flush==true while the pool tries to read any existing content
-// from the connection
-func (p *ConnectionPool) Get(timeout time.Duration, flush bool) (*Connection, error) {
-	expire := time.Now().Add(timeout)
+// See GetOptions for the settings that can be changed per call
+func (p *ConnectionPool) Get(timeout time.Duration, opts GetOptions) (*Connection, error) {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := p.GetContext(ctx, opts)
+	if err == context.DeadlineExceeded {
+		return nil, p.timeoutError(start)
+	}
+	return conn, err
+}
+
+// GetUntil is like Get but waits until an absolute deadline rather than for a duration,
+// returning ErrTimeout if no connection is available by then
+func (p *ConnectionPool) GetUntil(deadline time.Time, opts GetOptions) (*Connection, error) {
+	start := time.Now()
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	conn, err := p.GetContext(ctx, opts)
+	if err == context.DeadlineExceeded {
+		return nil, p.timeoutError(start)
+	}
+	return conn, err
+}
+
+// GetContext is like Get but instead of a fixed timeout it waits until either a connection
+// becomes available or the context is done. If the context is done first the context error
+// is returned, i.e. context.Canceled or context.DeadlineExceeded, not ErrTimeout. If the pool
+// is closed, either before or while waiting, ErrPoolClosed is returned
+func (p *ConnectionPool) GetContext(ctx context.Context, opts GetOptions) (*Connection, error) {
+	start := time.Now()
+	ctxTrace := getTrace(ctx)
+	done := func(info GetInfo) {
+		opts.Trace.done(info)
+		ctxTrace.done(info)
+	}
+	caller := p.auditCaller()
+	attrs := p.contextAttrs(ctx)
+	for {
+		conns, err := p.get(ctx, 1, opts, attrs)
+		if err != nil {
+			opts.logf("pool %q: failed to get a connection after %s: %s", p.Config.Name, time.Since(start), err)
+			done(GetInfo{Waited: time.Since(start), Err: err})
+			p.audit(AuditEntry{Op: AuditGet, Caller: caller, Duration: time.Since(start), Err: err})
+			if err == context.DeadlineExceeded {
+				p.count(MetricTimeouts, 1)
+				p.reportTimeout(ctx, time.Since(start), attrs)
+			}
+			return nil, err
+		}
+
+		conn := conns[0]
+		if p.Config.CheckHalfOpen {
+			if err := checkHalfOpen(conn.Conn); err != nil {
+				opts.logf("pool %q: connection is half open: %s", p.Config.Name, err)
+				p.Release(conn, err)
+				continue
+			}
+		}
+		if p.Config.CheckOnBorrow != nil {
+			if err := p.Config.CheckOnBorrow(conn); err != nil {
+				opts.logf("pool %q: connection failed the borrow check: %s", p.Config.Name, err)
+				p.Release(conn, err)
+				continue
+			}
+		}
+		if opts.Validate != nil {
+			if err := opts.Validate(conn); err != nil {
+				opts.logf("pool %q: connection failed validation: %s", p.Config.Name, err)
+				p.Release(conn, err)
+				continue
+			}
+		}
+		if p.Config.OnAcquire != nil {
+			if err := p.Config.OnAcquire(conn); err != nil {
+				opts.logf("pool %q: failed to prepare the connection: %s", p.Config.Name, err)
+				p.Release(conn, err)
+				continue
+			}
+		}
+
+		waited := time.Since(start)
+		p.recordWait(waited)
+		p.audit(AuditEntry{Op: AuditGet, Caller: caller, ConnID: conn.pc.id, Duration: waited})
+		p.reportSlowGet(ctx, waited, attrs)
+		p.timing(MetricWaitTime, waited)
+		p.reportGauges()
+		opts.logf("pool %q: got a connection after %s", p.Config.Name, waited)
+		dialed := conn.pc.createdAt.After(start)
+		done(GetInfo{Waited: waited, Dialed: dialed})
+		if p.Config.OnGet != nil {
+			p.safely("OnGet", func() { p.Config.OnGet(waited, dialed) })
+		}
+		return conn, nil
+	}
+}
+
+// GetN waits to get n connections from the pool at once. Either all n connections are
+// returned or, if they could not all be fetched within the timeout, none of them are and
+// ErrTimeout is returned. Connections are never held while waiting so concurrent callers
+// can't deadlock each other holding part of what they need
+func (p *ConnectionPool) GetN(n int, timeout time.Duration) ([]*Connection, error) {
+	p.mu.Lock()
+	max := p.maxSizeLocked()
+	p.mu.Unlock()
+	if n <= 0 || n > max {
+		return nil, fmt.Errorf("n must be between 1 and the pool size %d, got %d", max, n)
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conns, err := p.get(ctx, n, GetOptions{}, nil)
+	if err == context.DeadlineExceeded {
+		p.count(MetricTimeouts, 1)
+		return nil, p.timeoutError(start)
+	}
+	return conns, err
+}
+
+// TryGet returns an idle connection if there is one, otherwise it returns ErrExhausted
+// straight away rather than waiting for a connection to be released
+func (p *ConnectionPool) TryGet() (*Connection, error) {
+	stack := p.leakStack()
+	caller := p.auditCaller()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	c, err := p.tryGetLocked()
+	if err != nil {
+		p.auditLocked(AuditEntry{Op: AuditGet, Caller: caller, Err: err})
+		return nil, err
+	}
+	c.stack = stack
+	p.auditLocked(AuditEntry{Op: AuditGet, Caller: caller, ConnID: c.pc.id})
+	return c, nil
+}
+
+// tryGetLocked takes an idle connection for TryGet, p.mu must be held
+func (p *ConnectionPool) tryGetLocked() (*Connection, error) {
+	if err := p.unavailableLocked(); err != nil {
+		return nil, err
+	}
+	if p.paused {
+		return nil, ErrPaused
+	}
+	if len(p.waiters) > 0 || len(p.idle) == 0 {
+		p.exhaustedLocked(nil)
+		return nil, ErrExhausted
+	}
+	return p.takeLocked(1)[0], nil
+}
+
+// get waits for n connections to become available. If they already are they are returned
+// straight away, even if the context has already expired. attrs are the Config.ContextAttrs
+// for the call, added to its events
+func (p *ConnectionPool) get(ctx context.Context, n int, opts GetOptions, attrs []slog.Attr) ([]*Connection, error) {
+	p.mu.Lock()
+	if err := p.unavailableLocked(); err != nil {
+		p.mu.Unlock()
+		return nil, err
+	}
+
+	// Only take connections straight away if nobody is queued ahead of us
+	if !p.paused && len(p.waiters) == 0 && len(p.idle) >= n {
+		conns := p.takeLocked(n)
+		p.mu.Unlock()
+		return p.prepare(conns, opts.Flush), nil
+	}
+	if opts.NoWait {
+		err := ErrExhausted
+		if p.paused {
+			err = ErrPaused
+		} else {
+			p.exhaustedLocked(attrs)
+		}
+		p.mu.Unlock()
+		return nil, err
+	}
+	if p.circuitOpenLocked() {
+		p.mu.Unlock()
+		return nil, ErrCircuitOpen
+	}
+
+	w := &waiter{n: n, priority: opts.Priority, conns: make(chan []*Connection, 1), since: time.Now()}
+	p.enqueueLocked(w)
+	if !p.paused {
+		p.growLocked()
+		p.exhaustedLocked(attrs)
+	}
+	queued, stop := len(p.waiters), p.stop
+	p.mu.Unlock()
+	opts.logf("pool %q: waiting for a connection, %d callers queued", p.Config.Name, queued)
+
+	var err error
+	stopped := false
 	select {
-	case conn := <-p.pool:
-		if flush {
+	case conns := <-w.conns:
+		if conns == nil {
+			return nil, w.err
+		}
+		return p.prepare(conns, opts.Flush), nil
+	case <-p.closing:
+		err = ErrPoolClosed
+	case <-stop:
+		stopped = true
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err == context.DeadlineExceeded {
+		p.timeouts++
+	}
+	if stopped {
+		err = ErrDraining
+		if uerr := p.unavailableLocked(); uerr != nil {
+			err = uerr
+		}
+	}
+	for i, qw := range p.waiters {
+		if qw == w {
+			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+			return nil, err
+		}
+	}
+
+	// The waiter was served at the same time as giving up, hand the connections back
+	for _, c := range <-w.conns {
+		delete(p.inUse, c.pc)
+		p.putLocked(c.pc)
+	}
+	return nil, err
+}
+
+// unavailableLocked returns the error callers get when the pool isn't handing out connections
+// because it is closed, quiesced or draining, nil otherwise, p.mu must be held
+func (p *ConnectionPool) unavailableLocked() error {
+	switch {
+	case p.closed:
+		return ErrPoolClosed
+	case !p.quiescedUntil.IsZero():
+		return &QuiescedError{Until: p.quiescedUntil}
+	case p.draining:
+		return ErrDraining
+	}
+	return nil
+}
+
+// waitingForLocked returns the total number of connections the queued waiters need, p.mu
+// must be held
+func (p *ConnectionPool) waitingForLocked() int {
+	n := 0
+	for _, w := range p.waiters {
+		n += w.n
+	}
+	return n
+}
+
+// enqueueLocked adds the waiter to the queue behind any waiters with the same or higher
+// priority, p.mu must be held
+func (p *ConnectionPool) enqueueLocked(w *waiter) {
+	i := len(p.waiters)
+	for i > 0 && p.waiters[i-1].priority < w.priority {
+		i--
+	}
+	p.waiters = append(p.waiters, nil)
+	copy(p.waiters[i+1:], p.waiters[i:])
+	p.waiters[i] = w
+}
+
+// takeLocked removes n connections from the idle list, marks them as in use and returns
+// a new lease for each of them, p.mu must be held and there must be at least n idle
+// connections
+func (p *ConnectionPool) takeLocked(n int) []*Connection {
+	conns := make([]*Connection, n)
+	now := time.Now()
+	taken := p.idle[:n]
+	if p.Config.Reuse == ReuseLIFO {
+		taken = p.idle[len(p.idle)-n:]
+	}
+	for i, pc := range taken {
+		c := &Connection{Conn: pc.Conn, owner: p, pc: pc}
+		p.inUse[pc] = c
+		pc.lastUsed = now
+		pc.uses++
+		pc.setState(StateInUse)
+		conns[i] = c
+	}
+	if p.Config.Reuse == ReuseLIFO {
+		p.idle = p.idle[:len(p.idle)-n]
+	} else {
+		p.idle = append(p.idle[:0], p.idle[n:]...)
+	}
+	p.topUpLocked()
+	return conns
+}
+
+// putLocked adds the connection to the idle list then hands connections to the queued
+// waiters that can be satisfied, p.mu must be held
+func (p *ConnectionPool) putLocked(pc *pooledConn) {
+	if p.closed || p.draining {
+		reason := closeDrained
+		if p.closed {
+			reason = closePoolClosed
+		}
+		go p.discard(pc, reason)
+		return
+	}
+
+	pc.idleSince = time.Now()
+	pc.setState(StateIdle)
+	p.idle = append(p.idle, pc)
+	p.serveLocked()
+}
+
+// serveLocked hands idle connections to the queued waiters that can be satisfied, unless the
+// pool is paused, p.mu must be held
+func (p *ConnectionPool) serveLocked() {
+	for !p.paused && len(p.waiters) > 0 && len(p.idle) >= p.waiters[0].n {
+		w := p.waiters[0]
+		p.waiters = p.waiters[1:]
+		w.conns <- p.takeLocked(w.n)
+	}
+}
+
+// prepare gets the connections ready to be handed to the caller, it must be called from the
+// caller's goroutine
+func (p *ConnectionPool) prepare(conns []*Connection, flush bool) []*Connection {
+	p.trackLeases(conns)
+	if flush {
+		for _, conn := range conns {
 			// Read all the contents from the buffer, if there is any, then
 			// reset the read deadline to infinity
 			conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
-			_, _ = ioutil.ReadAll(conn)
+			_, _ = ioutil.ReadAll(conn.Conn)
 			conn.SetReadDeadline(time.Time{})
 		}
-		return conn, nil
-
-	case <-time.After(expire.Sub(time.Now())):
-		return nil, ErrTimeout
 	}
+	return conns
 }
 
 // Release returns the connection back to the pool. err is any error that was returned
 // by the connection while it was being used, if there was an error the pool will then
-// throw this connection away and create a new one
-func (p *ConnectionPool) Release(c *Connection, err error) {
+// throw this connection away and create a new one, the same happens if the connection
+// has been marked as bad. The new connection is created in the background so it is
+// usually ready before the next Get, except in lazy pools which wait until it is needed.
+// If the pool has been closed the connection is closed instead of being returned.
+// Releasing a lease that has already been released returns ErrAlreadyReleased, even if the
+// connection has since been checked out again, releasing one that wasn't created by this
+// pool returns ErrForeignConnection, neither has any other effect
+func (p *ConnectionPool) Release(c *Connection, err error) error {
 	if c == nil {
-		return
+		return nil
+	}
+	if c.owner != p {
+		return ErrForeignConnection
+	}
+
+	pc := c.pc
+	caller := p.auditCaller()
+	if err == nil && (p.Config.OnReturn != nil || p.Config.CheckOnReturn != nil) {
+		p.mu.Lock()
+		current := p.inUse[pc] == c
+		p.mu.Unlock()
+		if current && p.Config.OnReturn != nil {
+			err = p.Config.OnReturn(c)
+		}
+		if current && err == nil && p.Config.CheckOnReturn != nil {
+			err = p.Config.CheckOnReturn(c)
+		}
+	}
+	if err == nil && pc.reader != nil && pc.reader.Buffered() > 0 {
+		err = ErrUnreadData
+	}
+
+	p.mu.Lock()
+	if p.inUse[pc] != c {
+		// Either the connection isn't checked out or it has been checked out again
+		// by somebody else since this lease was released
+		_, known := p.conns[pc]
+		closed, reclaimed := p.closed, c.reclaimed
+		p.mu.Unlock()
+
+		switch {
+		case !known && !pc.isClosed():
+			// Not one of ours, and not one we have already closed either
+			return ErrForeignConnection
+		case reclaimed:
+			return ErrLeaseExpired
+		case closed:
+			// Once the pool is closed connections that are not marked as in use have
+			// been force closed so there is nothing to do
+			return nil
+		}
+		return ErrAlreadyReleased
+	}
+
+	delete(p.inUse, pc)
+	held := time.Since(pc.lastUsed)
+	stopped := closeDrained
+	if p.closed {
+		stopped = closePoolClosed
+	}
+	p.releases.add(time.Now())
+	p.auditLocked(AuditEntry{Op: AuditRelease, Caller: caller, ConnID: pc.id, Duration: held, Err: err})
+	closed := p.closed || p.draining
+	if p.closed && len(p.inUse) == 0 {
+		p.releasedLocked()
 	}
 
 	if err != nil {
-		p.retryNewConnection(nil)
+		atomic.AddInt64(&pc.errors, 1)
+	}
+	replacing := err != nil && pc.setBad()
+	if replacing {
+		p.countBadLocked(pc)
+	}
+	moved := err != nil && p.isFatal(err) && p.failOverLocked(pc.addr)
+	retiring := !closed && pc.isHealthy() && p.retiredLocked(pc, time.Now())
+	if !closed && pc.isHealthy() && !retiring && !p.surplusLocked() {
+		p.putLocked(pc)
+		p.mu.Unlock()
+		p.reportGauges()
+		p.onRelease(held, false)
+		p.afterRelease(c, err)
+		return nil
+	}
+	p.mu.Unlock()
+
+	if replacing {
+		p.count(MetricBadConns, 1)
+	}
+	if moved {
+		p.migrate()
+	}
+	reason := stopped
+	switch {
+	case !pc.isHealthy():
+		reason = evictBad
+		p.reportEvict(pc, reason, err)
+	case retiring:
+		reason = evictRetired
+		p.reportEvict(pc, reason, nil)
+	case !closed:
+		reason = evictSurplus
+		p.reportEvict(pc, reason, nil)
+	}
+	p.discard(pc, reason)
+	if replacing || retiring {
+		p.refill()
+	}
+	p.reportGauges()
+	p.onRelease(held, !pc.isHealthy())
+	p.afterRelease(c, err)
+	return nil
+}
+
+// onRelease calls Config.OnRelease if it is set, p.mu must not be held
+func (p *ConnectionPool) onRelease(held time.Duration, bad bool) {
+	if p.Config.OnRelease != nil {
+		p.safely("OnRelease", func() { p.Config.OnRelease(held, bad) })
+	}
+}
+
+// markBad marks the connection behind the lease as bad, as long as the lease is still the
+// one the connection is checked out with
+func (p *ConnectionPool) markBad(c *Connection) {
+	p.mu.Lock()
+	current := p.inUse[c.pc] == c
+	p.mu.Unlock()
+
+	if current && c.pc.setBad() {
+		p.mu.Lock()
+		p.countBadLocked(c.pc)
+		p.mu.Unlock()
+		p.count(MetricBadConns, 1)
+		p.replace(c.pc)
+	}
+}
+
+// Replace closes the checked out connection and creates a new one in its place, handing the
+// new connection back to the caller checked out in the same way as if it had come from Get.
+// The old lease can't be used afterwards. If the new connection can't be created the error
+// from Config.NewConnection is returned and the pool creates a replacement in the background
+// instead. Replacing a lease that has already been released returns ErrAlreadyReleased, one
+// that wasn't created by this pool returns ErrForeignConnection
+func (p *ConnectionPool) Replace(c *Connection) (*Connection, error) {
+	if c == nil || c.owner != p {
+		return nil, ErrForeignConnection
+	}
+
+	pc := c.pc
+	p.mu.Lock()
+	if p.inUse[pc] != c {
+		_, known := p.conns[pc]
+		p.mu.Unlock()
+		if !known && !pc.isClosed() {
+			return nil, ErrForeignConnection
+		}
+		return nil, ErrAlreadyReleased
+	}
+	delete(p.inUse, pc)
+	delete(p.conns, pc)
+	p.open--
+	p.pending++
+	p.notifyLocked()
+	p.mu.Unlock()
+	p.closeConn(pc, closeReplaced)
+
+	p.waitDialTurn(context.Background(), nil)
+	conn, addr, err := p.dial()
+	p.dialFinished()
+
+	stack := p.leakStack()
+	p.mu.Lock()
+	p.pending--
+	if err == nil && p.closed {
+		err = ErrPoolClosed
+		conn.Close()
+	}
+	if err != nil {
+		if p.closed && len(p.inUse) == 0 {
+			p.releasedLocked()
+		}
+		p.notifyLocked()
+		p.mu.Unlock()
+		if err != ErrPoolClosed && p.Config.OnDialError != nil {
+			p.safely("OnDialError", func() { p.Config.OnDialError(1, err) })
+		}
+		p.refill()
+		return nil, err
+	}
+
+	npc := p.newConnLocked(conn, addr, 1)
+	npc.lastUsed, npc.uses = npc.createdAt, 1
+	npc.setState(StateInUse)
+	nc := &Connection{Conn: conn, owner: p, pc: npc, stack: stack}
+	p.conns[npc] = struct{}{}
+	p.inUse[npc] = nc
+	p.open++
+	p.notifyLocked()
+	p.mu.Unlock()
+	return nc, nil
+}
+
+// newConnLocked wraps a connection made to addr after the given number of attempts, giving it
+// the next ID, p.mu must be held
+func (p *ConnectionPool) newConnLocked(c net.Conn, addr string, attempts int) *pooledConn {
+	p.lastID++
+	pc := &pooledConn{Conn: c, owner: p, state: StateDialing, id: p.lastID, createdAt: time.Now(), addr: addr, attempts: attempts}
+	p.newReaderLocked(pc)
+	pc.setState(StateEstablished)
+	return pc
+}
+
+// countBadLocked is called when a connection has been marked as bad while it was in use,
+// p.mu must be held
+func (p *ConnectionPool) countBadLocked(pc *pooledConn) {
+	p.quarantineLocked(pc)
+	p.detectRebootLocked()
+}
+
+// InvalidateAll marks every connection in the pool as bad, for example after a device has
+// rebooted and left all of its sessions half open. Idle connections are closed and replaced
+// straight away, checked out ones are closed when they are released
+func (p *ConnectionPool) InvalidateAll() {
+	p.mu.Lock()
+	conns := make([]*pooledConn, 0, len(p.conns))
+	for pc := range p.conns {
+		conns = append(conns, pc)
+	}
+	p.mu.Unlock()
+
+	for _, pc := range conns {
+		if pc.setBad() {
+			p.replace(pc)
+		}
+	}
+}
+
+// replace is called when a connection is marked as bad, it starts creating a new connection
+// to take its place and if the bad connection is idle it is closed straight away. Connections
+// the pool isn't tracking, because they were already closed or never belonged to the pool,
+// are ignored
+func (p *ConnectionPool) replace(pc *pooledConn) {
+	p.mu.Lock()
+	if _, ok := p.conns[pc]; !ok {
+		p.mu.Unlock()
 		return
 	}
-	p.pool <- c
+
+	for i, ipc := range p.idle {
+		if ipc == pc {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			p.mu.Unlock()
+			p.discard(pc, evictBad)
+			p.refill()
+			return
+		}
+	}
+	p.mu.Unlock()
+
+	p.refill()
 }
 
-func (p *ConnectionPool) retryNewConnection(wg *sync.WaitGroup) {
-	// Just keeps trying to open a new connection until it succeeds
-	go func() {
-		for !p.closed {
-			c, err := p.Config.NewConnection(p.Config)
+// WaitReady blocks until at least minConns connections have been established, so callers
+// can start using the pool before it has been completely filled. It returns the context error
+// if the context is done first, or ErrPoolClosed if the pool is closed while waiting
+func (p *ConnectionPool) WaitReady(ctx context.Context, minConns int) error {
+	p.mu.Lock()
+	max := p.maxSizeLocked()
+	p.mu.Unlock()
+	if minConns > max {
+		return fmt.Errorf("minConns %d is larger than the pool size %d", minConns, max)
+	}
+
+	for {
+		p.mu.Lock()
+		open, changed := p.open, p.changed
+		p.mu.Unlock()
+
+		if open >= minConns {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-p.closing:
+			return ErrPoolClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// discard closes the connection for reason and removes it from the count of open
+// connections, p.mu must not be held
+func (p *ConnectionPool) discard(pc *pooledConn, reason string) {
+	p.closeConn(pc, reason)
+	p.mu.Lock()
+	delete(p.conns, pc)
+	p.open--
+	p.notifyLocked()
+	p.mu.Unlock()
+}
+
+// closeConn calls Config.OnClose with the connection and the reason it is being closed, then
+// closes it, p.mu must not be held
+func (p *ConnectionPool) closeConn(pc *pooledConn, reason string) {
+	if p.Config.OnClose != nil && !pc.isClosed() {
+		p.safely("OnClose", func() { p.Config.OnClose(pc.Conn, reason) })
+	}
+	pc.destroy()
+}
+
+// releasedLocked signals that all of the connections have been released after the pool was
+// closed, p.mu must be held
+func (p *ConnectionPool) releasedLocked() {
+	select {
+	case <-p.released:
+	default:
+		close(p.released)
+	}
+}
+
+// notifyLocked wakes up everything waiting for the number of open connections to change,
+// p.mu must be held
+func (p *ConnectionPool) notifyLocked() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// dialHooks are called as the pool tries to open a new connection, either can be nil
+type dialHooks struct {
+	// failed is called every time an attempt fails, attempt counts from 1
+	failed func(attempt int, err error)
+
+	// done is called once the pool has stopped trying, with ok set to true if a connection
+	// was added to the pool
+	done func(ok bool)
+
+	// standby makes the new connection a standby connection rather than adding it to the
+	// idle list, it isn't counted in pending while it is created
+	standby bool
+
+	// reconnect marks the connection as replacing one that has been lost, the first attempt
+	// is delayed by up to Config.ReconnectJitter
+	reconnect bool
+}
+
+// retryNewConnection keeps trying to open a new connection until it succeeds, the pool is
+// closed or drained or the context is done, calling the hooks along the way
+func (p *ConnectionPool) retryNewConnection(ctx context.Context, hooks dialHooks) {
+	p.mu.Lock()
+	p.dialLocked(ctx, hooks)
+	p.mu.Unlock()
+}
+
+// dialLocked is retryNewConnection for callers that already hold p.mu
+func (p *ConnectionPool) dialLocked(ctx context.Context, hooks dialHooks) {
+	done, failed := hooks.done, hooks.failed
+	if done == nil {
+		done = func(bool) {}
+	}
+	if failed == nil {
+		failed = func(int, error) {}
+	}
+
+	if hooks.standby {
+		p.standbyPending++
+	} else {
+		p.pending++
+	}
+	stop := p.stop
+
+	// finishedLocked stops counting the attempt as in progress, p.mu must be held
+	finishedLocked := func() {
+		if hooks.standby {
+			p.standbyPending--
+		} else {
+			p.pending--
+		}
+	}
+
+	p.goLabeled("dial", func() {
+		if hooks.reconnect && !p.waitJitter(ctx, stop) {
+			p.mu.Lock()
+			finishedLocked()
+			p.notifyLocked()
+			p.mu.Unlock()
+			done(false)
+			return
+		}
+
+		for attempt := 1; !isDone(stop) && ctx.Err() == nil; attempt++ {
+			if (attempt > 1 && !p.waitRetryBudget(ctx, stop)) || !p.waitQuarantine(ctx, stop) || !p.waitDialTurn(ctx, stop) {
+				break
+			}
+			c, addr, err := p.dial()
+			p.dialFinished()
 			if err == nil {
-				p.pool <- NewConnection(c, p)
-				if wg != nil {
-					wg.Done()
+				p.mu.Lock()
+				finishedLocked()
+				p.dialSucceededLocked()
+				if isDone(stop) {
+					p.notifyLocked()
+					p.mu.Unlock()
+					c.Close()
+					done(false)
+					return
+				}
+				pc := p.newConnLocked(c, addr, attempt)
+				if hooks.standby {
+					p.standby = append(p.standby, pc)
+					p.mu.Unlock()
+					done(true)
+					return
 				}
+				p.conns[pc] = struct{}{}
+				p.open++
+				p.notifyLocked()
+				p.putLocked(pc)
+				p.mu.Unlock()
+				done(true)
+				return
+			}
+
+			p.mu.Lock()
+			p.dialFailedLocked(err)
+			p.mu.Unlock()
+			failed(attempt, err)
+			if p.Config.OnDialError != nil {
+				p.safely("OnDialError", func() { p.Config.OnDialError(attempt, err) })
+			}
+			if attempt == p.Config.MaxDialAttempts || (p.isFatal(err) && p.Config.Failover == nil) {
+				p.mu.Lock()
+				finishedLocked()
+				if !hooks.standby {
+					p.giveUpLocked(fmt.Errorf("%w: %v", ErrDialFailed, err))
+				}
+				p.notifyLocked()
+				p.mu.Unlock()
+				done(false)
 				return
 			}
 
 			// Wait for a small time then retry
-			time.Sleep(p.Config.RetryDuration)
+			select {
+			case <-time.After(p.retryDelay(attempt)):
+			case <-stop:
+			case <-ctx.Done():
+			}
 		}
-	}()
+
+		p.mu.Lock()
+		finishedLocked()
+		p.notifyLocked()
+		p.mu.Unlock()
+		done(false)
+	})
+}
+
+// giveUpLocked fails every queued waiter with err if there is nothing left that could serve
+// them, no open connections to be released and no connection attempts in progress, p.mu must
+// be held
+func (p *ConnectionPool) giveUpLocked(err error) {
+	if p.open > 0 || p.pending > 0 {
+		return
+	}
+	for _, w := range p.waiters {
+		w.err = err
+		w.conns <- nil
+	}
+	p.waiters = nil
+}
+
+// refill replaces a connection that has been thrown away, unless the pool already has enough
+// healthy connections because it has been shrunk. Lazy pools don't refill, the next Get that
+// needs a connection creates one instead. Nothing is refilled while the pool is recovering
+// from a device reboot, recoverReboot replaces every connection at once instead
+func (p *ConnectionPool) refill() {
+	if p.Config.Lazy {
+		return
+	}
+
+	p.mu.Lock()
+	if p.rebooting {
+		p.mu.Unlock()
+		return
+	}
+	if p.healthyLocked()+p.pending < p.size && !p.promoteStandbyLocked() {
+		p.dialLocked(context.Background(), dialHooks{reconnect: true})
+	}
+	p.mu.Unlock()
+}
+
+// startWorkers starts the pool's background goroutines, they all exit once the pool is closed
+// or drained, p.mu must be held
+func (p *ConnectionPool) startWorkers() {
+	// The workers get the channel now, p.stop is replaced when the pool is drained
+	stop := p.stop
+	if p.Config.AutoScale != nil {
+		scale := *p.Config.AutoScale
+		p.goLabeled("autoscale", func() { p.autoScale(scale, stop) })
+	}
+	if p.Config.IdleTimeout > 0 || p.Config.MaxConnLifetime > 0 {
+		p.goLabeled("reaper", func() { p.reap(stop) })
+	}
+	if p.Config.HealthCheckInterval > 0 {
+		p.goLabeled("health-check", func() { p.healthCheck(stop) })
+	}
+	if p.Config.HeartbeatInterval > 0 {
+		p.goLabeled("heartbeat", func() { p.heartbeat(stop) })
+	}
+	if p.Config.MonitorInterval > 0 {
+		p.goLabeled("monitor", func() { p.monitor(stop) })
+	}
+	if p.Config.LeakTimeout > 0 {
+		p.goLabeled("leak-detector", func() { p.detectLeaks(stop) })
+	}
+	if p.Config.MaxLeaseDuration > 0 {
+		p.goLabeled("lease-reclaimer", func() { p.reclaimLeases(stop) })
+	}
+	if p.Config.StallThreshold > 0 {
+		p.goLabeled("watchdog", func() { p.watchdog(stop) })
+	}
+	if p.Config.NetworkCheckInterval > 0 {
+		p.goLabeled("network-watcher", func() { p.watchNetwork(stop) })
+	}
+	if p.failoverIndex != 0 {
+		p.probing = true
+		p.goLabeled("failover-probe", func() { p.probePrimary(stop) })
+	}
+}
+
+// recordWait records how long a Get call waited for its connection
+func (p *ConnectionPool) recordWait(d time.Duration) {
+	p.mu.Lock()
+	p.waitTotal += d
+	p.waitCount++
+	p.waited += d
+	p.waitTimes.observe(d)
+	p.mu.Unlock()
+}
+
+// isDone returns true if the channel has been closed
+func isDone(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
 }
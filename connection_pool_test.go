@@ -1,8 +1,13 @@
 package pool_test
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -24,135 +29,173 @@ func (c *mockConn) Close() error {
 
 func TestInitCreatesConnections(t *testing.T) {
 
-	initCount := 0
-	p := pool.NewPool(pool.Config{
+	var initCount int32
+	p, err := pool.NewPool(pool.Config{
 		Size: 5,
 		NewConnection: func(cfg pool.Config) (net.Conn, error) {
-			initCount++
+			atomic.AddInt32(&initCount, 1)
 			return &mockConn{}, nil
 		},
 	})
+	require.Nil(t, err)
 
 	// Init and wait for completion
 	done := p.Init()
 	<-done
 
-	require.Equal(t, p.Config.Size, initCount)
+	require.Equal(t, int32(p.Config.Size), atomic.LoadInt32(&initCount))
 }
 
 func TestPoolCloseClosesAllConnections(t *testing.T) {
-	newCount := 0
-	closeCount := 0
-	p := pool.NewPool(pool.Config{
+	var newCount, closeCount int32
+	p, err := pool.NewPool(pool.Config{
 		Size: 5,
 		NewConnection: func(cfg pool.Config) (net.Conn, error) {
-			newCount++
+			atomic.AddInt32(&newCount, 1)
 			return &mockConn{
 				CloseCalled: func(c *mockConn) {
-					closeCount++
+					atomic.AddInt32(&closeCount, 1)
 				},
 			}, nil
 		},
 	})
+	require.Nil(t, err)
 
 	done := p.Init()
 	<-done
 
 	// The pool should not try to open connections again when it closes
 	// them when it shuts down
-	newCount = 0
+	atomic.StoreInt32(&newCount, 0)
 	closed := p.Close()
 	<-closed
 
-	require.Equal(t, 0, newCount)
-	require.Equal(t, p.Config.Size, closeCount)
+	require.Equal(t, int32(0), atomic.LoadInt32(&newCount))
+	require.Equal(t, int32(p.Config.Size), atomic.LoadInt32(&closeCount))
 }
 
 func TestGetReturnsConnectionsAndErrsOnTimeout(t *testing.T) {
-	p := pool.NewPool(pool.Config{
+	p, err := pool.NewPool(pool.Config{
 		Size: 3,
 		NewConnection: func(cfg pool.Config) (net.Conn, error) {
 			return &mockConn{}, nil
 		},
 	})
+	require.Nil(t, err)
 
 	done := p.Init()
 	<-done
 
 	// Should be able to call Get 3 times, then timeout and get error on 4th
 	for i := 0; i < p.Config.Size; i++ {
-		c, err := p.Get(time.Second, false)
+		c, err := p.Get(time.Second, pool.GetOptions{})
 		require.Nil(t, err)
 		require.NotNil(t, c)
 	}
 
 	start := time.Now()
-	c, err := p.Get(time.Millisecond, false)
+	c, err := p.Get(time.Millisecond, pool.GetOptions{})
 	end := time.Now()
 
 	require.Nil(t, c)
 	require.NotNil(t, err)
-	require.Equal(t, err, pool.ErrTimeout)
+	require.ErrorIs(t, err, pool.ErrTimeout)
 	require.True(t, end.Sub(start) >= time.Millisecond)
 }
 
+func TestTimeoutErrorDescribesThePool(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Name: "bridge",
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	defer c.Close()
+
+	waiting := make(chan struct{})
+	go func() {
+		defer close(waiting)
+		p.Get(200*time.Millisecond, pool.GetOptions{})
+	}()
+	require.Eventually(t, func() bool { return p.Stats().Waiters == 1 }, time.Second, time.Millisecond)
+
+	_, err = p.Get(20*time.Millisecond, pool.GetOptions{})
+	var timeout *pool.TimeoutError
+	require.ErrorAs(t, err, &timeout)
+	require.Equal(t, "bridge", timeout.Pool)
+	require.Equal(t, 1, timeout.Size)
+	require.Equal(t, 1, timeout.Waiters)
+	require.True(t, timeout.Waited >= 20*time.Millisecond)
+	require.Contains(t, err.Error(), `timeout: no connection from pool "bridge" of size 1 after`)
+	<-waiting
+}
+
 func TestCloseReturnsTheConnectionToThePool(t *testing.T) {
-	p := pool.NewPool(pool.Config{
+	p, err := pool.NewPool(pool.Config{
 		Size: 1,
 		NewConnection: func(cfg pool.Config) (net.Conn, error) {
 			return &mockConn{}, nil
 		},
 	})
+	require.Nil(t, err)
 
 	done := p.Init()
 	<-done
 
-	c1, err := p.Get(time.Millisecond, false)
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{})
 	require.NotNil(t, c1)
 	require.Nil(t, err)
 
 	// Second call should have run out of connections
-	c, err := p.Get(time.Millisecond, false)
-	require.Equal(t, err, pool.ErrTimeout)
+	c, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.ErrorIs(t, err, pool.ErrTimeout)
 	require.Nil(t, c)
 
 	p.Release(c1, nil)
-	c2, err := p.Get(time.Millisecond, false)
+	c2, err := p.Get(time.Millisecond, pool.GetOptions{})
 	require.NotNil(t, c2)
 	require.Nil(t, err)
-	require.Equal(t, c1, c2)
+	require.True(t, c1.Conn == c2.Conn)
 }
 
 func TestBadConnectionNotReturnedToThePool(t *testing.T) {
 	newCalled := false
-	p := pool.NewPool(pool.Config{
+	p, err := pool.NewPool(pool.Config{
 		Size: 1,
 		NewConnection: func(cfg pool.Config) (net.Conn, error) {
 			newCalled = true
 			return &mockConn{}, nil
 		},
 	})
+	require.Nil(t, err)
 
 	done := p.Init()
 	<-done
 
-	c1, err := p.Get(time.Millisecond, false)
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{})
 	require.NotNil(t, c1)
 	require.Nil(t, err)
 
 	newCalled = false
 	p.Release(c1, errors.New(""))
 
-	c2, err := p.Get(time.Millisecond*100, false)
+	c2, err := p.Get(time.Millisecond*100, pool.GetOptions{})
 	require.NotNil(t, c2)
 	require.Nil(t, err)
-	require.False(t, c1 == c2)
+	require.False(t, c1.Conn == c2.Conn)
 	require.True(t, newCalled)
 }
 
 func TestPoolKeepsTryingToOpenConnectionUntilSuccess(t *testing.T) {
 	newCount := 0
-	p := pool.NewPool(pool.Config{
+	p, err := pool.NewPool(pool.Config{
 		Size: 1,
 		NewConnection: func(cfg pool.Config) (net.Conn, error) {
 			newCount++
@@ -164,9 +207,1333 @@ func TestPoolKeepsTryingToOpenConnectionUntilSuccess(t *testing.T) {
 			return &mockConn{}, nil
 		},
 	})
+	require.Nil(t, err)
 
 	done := p.Init()
 	<-done
 
 	require.Equal(t, 5, newCount)
 }
+
+func TestGetContextReturnsContextErrorWhenCancelled(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.GetContext(context.Background(), pool.GetOptions{})
+	require.NotNil(t, c1)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	c, err := p.GetContext(ctx, pool.GetOptions{})
+	require.Nil(t, c)
+	require.Equal(t, context.Canceled, err)
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	c, err = p.GetContext(ctx, pool.GetOptions{})
+	require.Nil(t, c)
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.NotErrorIs(t, err, pool.ErrTimeout)
+}
+
+func TestInitContextReportsEstablishedConnectionsOnCancel(t *testing.T) {
+	var newCount int32
+	p, err := pool.NewPool(pool.Config{
+		Size:          3,
+		RetryDuration: time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			// Only the first connection can ever be created
+			if atomic.AddInt32(&newCount, 1) > 1 {
+				return nil, errors.New("device unavailable")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	result := <-p.InitContext(ctx)
+	require.Equal(t, 1, result.Established)
+	require.Equal(t, context.DeadlineExceeded, result.Err)
+}
+
+func TestCloseContextForceClosesCheckedOutConnections(t *testing.T) {
+	var closeCount int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 2,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{
+				CloseCalled: func(c *mockConn) {
+					atomic.AddInt32(&closeCount, 1)
+				},
+			}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.NotNil(t, c)
+	require.Nil(t, err)
+
+	// The idle connection is closed straight away, the checked out one once the
+	// grace period has expired
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	closed := p.CloseContext(ctx)
+	<-closed
+	require.Equal(t, int32(2), atomic.LoadInt32(&closeCount))
+}
+
+func TestCloseContextWaitsForReleasedConnections(t *testing.T) {
+	var closeCount int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{
+				CloseCalled: func(c *mockConn) {
+					atomic.AddInt32(&closeCount, 1)
+				},
+			}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.NotNil(t, c)
+	require.Nil(t, err)
+
+	closed := p.CloseContext(context.Background())
+	select {
+	case <-closed:
+		t.Fatal("close should wait for the checked out connection")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	p.Release(c, nil)
+	<-closed
+	require.Equal(t, int32(1), atomic.LoadInt32(&closeCount))
+}
+
+func TestNewPoolRejectsInvalidConfig(t *testing.T) {
+	newConn := func(cfg pool.Config) (net.Conn, error) {
+		return &mockConn{}, nil
+	}
+
+	configs := []pool.Config{
+		{Size: 0, NewConnection: newConn},
+		{Size: 1, RetryDuration: -time.Second, NewConnection: newConn},
+		{Size: 1},
+	}
+	for _, cfg := range configs {
+		p, err := pool.NewPool(cfg)
+		require.Nil(t, p)
+		require.True(t, errors.Is(err, pool.ErrInvalidConfig))
+	}
+}
+
+func TestStatsReportsIdleAndInUseConnections(t *testing.T) {
+	cp, err := pool.NewPool(pool.Config{
+		Size: 3,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	// Exercise the pool through the interface consumers are expected to use
+	var p pool.Pooler = cp
+
+	done := p.Init()
+	<-done
+
+	c, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	s := p.Stats()
+	require.Equal(t, 3, s.Size)
+	require.Equal(t, 2, s.Idle)
+	require.Equal(t, 1, s.InUse)
+
+	p.Release(c, nil)
+	s = p.Stats()
+	require.Equal(t, 3, s.Size)
+	require.Equal(t, 3, s.Idle)
+	require.Equal(t, 0, s.InUse)
+}
+
+func TestGetAfterCloseReturnsErrPoolClosed(t *testing.T) {
+	closeCalled := false
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{
+				CloseCalled: func(c *mockConn) {
+					closeCalled = true
+				},
+			}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.NotNil(t, c1)
+	require.Nil(t, err)
+
+	// A caller waiting for a connection is woken up when the pool closes
+	waitErr := make(chan error, 1)
+	go func() {
+		_, err := p.Get(time.Minute, pool.GetOptions{})
+		waitErr <- err
+	}()
+
+	closed := p.Close()
+	<-closed
+	require.Equal(t, pool.ErrPoolClosed, <-waitErr)
+
+	start := time.Now()
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, c)
+	require.Equal(t, pool.ErrPoolClosed, err)
+	require.True(t, time.Since(start) < time.Second)
+
+	// Releasing after close closes the connection rather than pooling it
+	require.False(t, closeCalled)
+	p.Release(c1, nil)
+	require.True(t, closeCalled)
+}
+
+func TestRepeatedInitAndCloseAreSafe(t *testing.T) {
+	var newCount, closeCount int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 3,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{
+				CloseCalled: func(c *mockConn) {
+					atomic.AddInt32(&closeCount, 1)
+				},
+			}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	first := p.Init()
+	second := p.Init()
+	<-first
+	<-second
+	<-p.Init()
+	require.Equal(t, int32(3), atomic.LoadInt32(&newCount))
+
+	first = p.Close()
+	second = p.Close()
+	<-first
+	<-second
+	<-p.CloseContext(context.Background())
+	require.Equal(t, int32(3), atomic.LoadInt32(&closeCount))
+}
+
+func TestWaitReadyReturnsOnceEnoughConnectionsAreOpen(t *testing.T) {
+	var newCount int32
+	unblock := make(chan struct{})
+	p, err := pool.NewPool(pool.Config{
+		Size: 3,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			// The first connection is quick, the others wait to be unblocked
+			if atomic.AddInt32(&newCount, 1) > 1 {
+				<-unblock
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+
+	err = p.WaitReady(context.Background(), 1)
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = p.WaitReady(ctx, 2)
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	err = p.WaitReady(context.Background(), 4)
+	require.NotNil(t, err)
+
+	close(unblock)
+	<-done
+	err = p.WaitReady(context.Background(), 3)
+	require.Nil(t, err)
+}
+
+func TestDiscardReplacesTheConnection(t *testing.T) {
+	var newCount int32
+	closeCalled := false
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{
+				CloseCalled: func(c *mockConn) {
+					closeCalled = true
+				},
+			}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+
+	err = c1.Discard()
+	require.Nil(t, err)
+	require.True(t, closeCalled)
+
+	c2, err := p.Get(time.Millisecond*100, pool.GetOptions{})
+	require.Nil(t, err)
+	require.False(t, c1.Conn == c2.Conn)
+	require.Equal(t, int32(2), atomic.LoadInt32(&newCount))
+}
+
+func TestTryGetDoesNotWait(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.TryGet()
+	require.NotNil(t, c1)
+	require.Nil(t, err)
+
+	c, err := p.TryGet()
+	require.Nil(t, c)
+	require.Equal(t, pool.ErrExhausted, err)
+
+	p.Release(c1, nil)
+	<-p.Close()
+	c, err = p.TryGet()
+	require.Nil(t, c)
+	require.Equal(t, pool.ErrPoolClosed, err)
+}
+
+func TestGetNAcquiresAllOrNothing(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 3,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+
+	// Only 2 connections are free, so asking for 3 mustn't take any of them
+	conns, err := p.GetN(3, 10*time.Millisecond)
+	require.Nil(t, conns)
+	require.ErrorIs(t, err, pool.ErrTimeout)
+	require.Equal(t, 2, p.Stats().Idle)
+
+	// Once the connection is released the waiting caller gets all 3
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		p.Release(c1, nil)
+	}()
+	conns, err = p.GetN(3, time.Second)
+	require.Nil(t, err)
+	require.Len(t, conns, 3)
+	require.Equal(t, 3, p.Stats().InUse)
+
+	_, err = p.GetN(4, time.Millisecond)
+	require.NotNil(t, err)
+}
+
+func TestMarkBadReplacesConnectionBeforeRelease(t *testing.T) {
+	var newCount int32
+	closeCalled := false
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{
+				CloseCalled: func(c *mockConn) {
+					closeCalled = true
+				},
+			}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	require.True(t, c1.IsHealthy())
+
+	c1.MarkBad()
+	c1.MarkBad()
+	require.False(t, c1.IsHealthy())
+
+	// The replacement is available while the bad connection is still checked out
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.False(t, c1.Conn == c2.Conn)
+	require.Equal(t, int32(2), atomic.LoadInt32(&newCount))
+
+	require.False(t, closeCalled)
+	p.Release(c1, nil)
+	require.True(t, closeCalled)
+	require.Equal(t, 0, p.Stats().Idle)
+}
+
+func TestDoubleReleaseIsDetected(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 2,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, nil))
+	require.Equal(t, pool.ErrAlreadyReleased, p.Release(c, nil))
+	require.Equal(t, pool.ErrAlreadyReleased, c.Close())
+
+	// The connection must only be in the pool once
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	c2, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	require.False(t, c1.Conn == c2.Conn)
+	require.Equal(t, 0, p.Stats().Idle)
+}
+
+func TestReleaseRejectsForeignConnections(t *testing.T) {
+	newPool := func() *pool.ConnectionPool {
+		p, err := pool.NewPool(pool.Config{
+			Size: 1,
+			NewConnection: func(cfg pool.Config) (net.Conn, error) {
+				return &mockConn{}, nil
+			},
+		})
+		require.Nil(t, err)
+		<-p.Init()
+		return p
+	}
+	p1 := newPool()
+	p2 := newPool()
+
+	c, err := p1.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Equal(t, pool.ErrForeignConnection, p2.Release(c, nil))
+	require.Equal(t, pool.ErrForeignConnection, p1.Release(pool.NewConnection(&mockConn{}, p1), nil))
+	require.Equal(t, 1, p2.Stats().Idle)
+
+	// Once discarded the connection is no longer tracked but is still recognised
+	require.Nil(t, c.Discard())
+	require.Equal(t, pool.ErrAlreadyReleased, p1.Release(c, nil))
+}
+
+func TestGetUntilHonoursTheDeadline(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	// A deadline in the past still returns an available connection
+	c1, err := p.GetUntil(time.Now().Add(-time.Second), pool.GetOptions{})
+	require.NotNil(t, c1)
+	require.Nil(t, err)
+
+	deadline := time.Now().Add(10 * time.Millisecond)
+	c, err := p.GetUntil(deadline, pool.GetOptions{})
+	require.Nil(t, c)
+	require.ErrorIs(t, err, pool.ErrTimeout)
+	require.False(t, time.Now().Before(deadline))
+}
+
+func TestGetOptionsValidateReplacesFailingConnections(t *testing.T) {
+	var newCount int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	// Validation failures throw the connection away and wait for a new one
+	validated := 0
+	c, err := p.Get(time.Second, pool.GetOptions{
+		Validate: func(c net.Conn) error {
+			validated++
+			if validated == 1 {
+				return errors.New("stale")
+			}
+			return nil
+		},
+	})
+	require.NotNil(t, c)
+	require.Nil(t, err)
+	require.Equal(t, 2, validated)
+	require.Equal(t, int32(2), atomic.LoadInt32(&newCount))
+}
+
+func TestGetOptionsLogfCanInspectThePool(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+
+	// Logf is called while waiting, it must be able to call back into the pool
+	var logged []string
+	c, err := p.Get(10*time.Millisecond, pool.GetOptions{
+		Logf: func(format string, args ...interface{}) {
+			logged = append(logged, fmt.Sprintf(format, args...))
+			p.Stats()
+			p.Dump()
+		},
+	})
+	require.Nil(t, c)
+	require.ErrorIs(t, err, pool.ErrTimeout)
+	require.Len(t, logged, 2)
+	p.Release(c1, nil)
+}
+
+func TestGetOptionsNoWaitFailsFast(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{NoWait: true})
+	require.NotNil(t, c1)
+	require.Nil(t, err)
+
+	c, err := p.Get(time.Second, pool.GetOptions{NoWait: true})
+	require.Nil(t, c)
+	require.Equal(t, pool.ErrExhausted, err)
+}
+
+func TestGetOptionsPriorityServesHigherPriorityFirst(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+
+	// Higher priority callers are served before callers that have been waiting longer
+	order := make(chan int, 2)
+	errs := make(chan error, 2)
+	for i, priority := range []int{0, 1} {
+		go func(priority int) {
+			c, err := p.Get(time.Second, pool.GetOptions{Priority: priority})
+			if err != nil {
+				errs <- err
+				return
+			}
+			order <- priority
+			errs <- p.Release(c, nil)
+		}(priority)
+
+		for p.Dump().Waiters != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	p.Release(c1, nil)
+	require.Nil(t, <-errs)
+	require.Nil(t, <-errs)
+	require.Equal(t, 1, <-order)
+	require.Equal(t, 0, <-order)
+}
+
+func TestLazyPoolOpensConnectionsOnDemand(t *testing.T) {
+	var newCount int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 2,
+		Lazy: true,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	result := <-p.InitContext(context.Background())
+	require.Equal(t, 0, result.Established)
+	require.Equal(t, int32(0), atomic.LoadInt32(&newCount))
+
+	c1, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&newCount))
+
+	// The released connection is reused rather than opening another
+	p.Release(c1, nil)
+	c1, err = p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&newCount))
+
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.False(t, c1.Conn == c2.Conn)
+	require.Equal(t, int32(2), atomic.LoadInt32(&newCount))
+
+	// Never more than Size connections
+	_, err = p.Get(10*time.Millisecond, pool.GetOptions{})
+	require.ErrorIs(t, err, pool.ErrTimeout)
+	require.Equal(t, int32(2), atomic.LoadInt32(&newCount))
+
+	// Bad connections aren't replaced until needed
+	p.Release(c1, errors.New("reset"))
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, int32(2), atomic.LoadInt32(&newCount))
+}
+
+func TestEveryCheckoutGetsANewLease(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, c1.Close())
+
+	// Same underlying connection, different lease
+	c2, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	require.True(t, c1.Conn == c2.Conn)
+	require.False(t, c1 == c2)
+}
+
+func TestMarkBadIgnoresUntrackedConnections(t *testing.T) {
+	var newCount int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	pool.NewConnection(&mockConn{}, p).MarkBad()
+
+	// A discarded connection marked bad afterwards doesn't get replaced again
+	c, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, c.Discard())
+	require.Nil(t, p.WaitReady(context.Background(), 1))
+	c.MarkBad()
+
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, int32(2), atomic.LoadInt32(&newCount))
+	require.Len(t, p.Dump().Conns, 1)
+}
+
+func TestStaleLeaseCannotReleaseOrMarkBadTheNextHolder(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	a, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(a, nil))
+
+	b, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	require.True(t, a.Conn == b.Conn)
+
+	// a's lease is stale, it mustn't hand b's connection to anybody else
+	require.Equal(t, pool.ErrAlreadyReleased, p.Release(a, nil))
+	a.MarkBad()
+	require.True(t, b.IsHealthy())
+
+	c, err := p.Get(10*time.Millisecond, pool.GetOptions{})
+	require.Nil(t, c)
+	require.ErrorIs(t, err, pool.ErrTimeout)
+	require.Nil(t, p.Release(b, nil))
+}
+
+func TestReuseStrategyPicksTheIdleConnection(t *testing.T) {
+	for _, reuse := range []pool.ReuseStrategy{pool.ReuseFIFO, pool.ReuseLIFO} {
+		p, err := pool.NewPool(pool.Config{
+			Size:  2,
+			Reuse: reuse,
+			NewConnection: func(cfg pool.Config) (net.Conn, error) {
+				return &mockConn{}, nil
+			},
+		})
+		require.Nil(t, err)
+
+		done := p.Init()
+		<-done
+
+		c1, err := p.Get(time.Second, pool.GetOptions{})
+		require.Nil(t, err)
+		c2, err := p.Get(time.Second, pool.GetOptions{})
+		require.Nil(t, err)
+		require.Nil(t, p.Release(c1, nil))
+		require.Nil(t, p.Release(c2, nil))
+
+		c3, err := p.Get(time.Second, pool.GetOptions{})
+		require.Nil(t, err)
+		if reuse == pool.ReuseLIFO {
+			require.True(t, c3.Conn == c2.Conn)
+		} else {
+			require.True(t, c3.Conn == c1.Conn)
+		}
+		require.Nil(t, p.Release(c3, nil))
+		<-p.Close()
+	}
+}
+
+func TestInvalidateAllReplacesEveryConnection(t *testing.T) {
+	var dials, closes int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 2,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return &mockConn{CloseCalled: func(*mockConn) {
+				atomic.AddInt32(&closes, 1)
+			}}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+
+	p.InvalidateAll()
+	require.False(t, c.IsHealthy())
+	require.Equal(t, int32(1), atomic.LoadInt32(&closes))
+
+	require.Nil(t, p.Release(c, nil))
+	require.Equal(t, int32(2), atomic.LoadInt32(&closes))
+	require.Eventually(t, func() bool {
+		return p.Stats().Idle == 2
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int32(4), atomic.LoadInt32(&dials))
+	<-p.Close()
+}
+
+func TestReplaceHandsBackANewConnection(t *testing.T) {
+	var dials, closes int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 3 {
+				return nil, errors.New("dial failed")
+			}
+			return &mockConn{CloseCalled: func(*mockConn) {
+				atomic.AddInt32(&closes, 1)
+			}}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	c2, err := p.Replace(c1)
+	require.Nil(t, err)
+	require.False(t, c1.Conn == c2.Conn)
+	require.Equal(t, int32(1), atomic.LoadInt32(&closes))
+	require.Equal(t, 1, p.Stats().InUse)
+	require.Equal(t, pool.ErrAlreadyReleased, p.Release(c1, nil))
+
+	// A failed dial is retried in the background
+	_, err = p.Replace(c2)
+	require.EqualError(t, err, "dial failed")
+	c3, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c3, nil))
+	<-p.Close()
+}
+
+func TestInitWithProgressReportsEachConnection(t *testing.T) {
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Size:          2,
+		RetryDuration: time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				return nil, errors.New("dial failed")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	result, progress := p.InitWithProgress(context.Background())
+	var last pool.InitProgress
+	for pr := range progress {
+		require.Equal(t, 2, pr.Established+pr.Remaining)
+		last = pr
+	}
+	require.Equal(t, pool.InitProgress{Established: 2, Failed: 1}, last)
+	require.Equal(t, pool.InitResult{Established: 2, LastDialErr: errors.New("dial failed")}, <-result)
+
+	// Once initialized the progress channel is closed straight away
+	_, progress = p.InitWithProgress(context.Background())
+	_, ok := <-progress
+	require.False(t, ok)
+	<-p.Close()
+}
+
+func TestOnDialErrorReportsEveryFailedAttempt(t *testing.T) {
+	var dials int32
+	type failure struct {
+		attempt int
+		err     error
+	}
+	failures := make(chan failure, 10)
+	p, err := pool.NewPool(pool.Config{
+		Size:          1,
+		RetryDuration: time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if n := atomic.AddInt32(&dials, 1); n < 3 {
+				return nil, fmt.Errorf("dial %d failed", n)
+			}
+			return &mockConn{}, nil
+		},
+		OnDialError: func(attempt int, err error) {
+			failures <- failure{attempt, err}
+		},
+	})
+	require.Nil(t, err)
+
+	result := <-p.InitContext(context.Background())
+	require.Equal(t, 1, result.Established)
+	require.EqualError(t, result.LastDialErr, "dial 2 failed")
+
+	close(failures)
+	var got []failure
+	for f := range failures {
+		got = append(got, f)
+	}
+	require.Equal(t, []failure{{1, errors.New("dial 1 failed")}, {2, errors.New("dial 2 failed")}}, got)
+	<-p.Close()
+}
+
+func TestPoolGivesUpAfterMaxDialAttempts(t *testing.T) {
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Size:            1,
+		MaxDialAttempts: 3,
+		RetryDuration:   time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return nil, errors.New("device unplugged")
+		},
+	})
+	require.Nil(t, err)
+
+	result := <-p.InitContext(context.Background())
+	require.Equal(t, 0, result.Established)
+	require.True(t, errors.Is(result.Err, pool.ErrDialFailed))
+	require.Equal(t, int32(3), atomic.LoadInt32(&dials))
+
+	// A Get starts trying again, then fails once those attempts are used up too
+	_, err = p.Get(time.Second, pool.GetOptions{})
+	require.True(t, errors.Is(err, pool.ErrDialFailed))
+	require.EqualError(t, err, "failed to create a connection: device unplugged")
+	require.Equal(t, int32(6), atomic.LoadInt32(&dials))
+	<-p.Close()
+}
+
+func TestBadConnectionIsReplacedBeforeTheNextGet(t *testing.T) {
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, errors.New("broken pipe")))
+
+	require.Eventually(t, func() bool {
+		return p.Stats().Idle == 1
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int32(2), atomic.LoadInt32(&dials))
+	<-p.Close()
+}
+
+func TestCheckOnBorrowReplacesDeadConnections(t *testing.T) {
+	var dials int32
+	dead := &mockConn{}
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				return dead, nil
+			}
+			return &mockConn{}, nil
+		},
+		CheckOnBorrow: func(c net.Conn) error {
+			if c.(*pool.Connection).Conn == net.Conn(dead) {
+				return errors.New("connection is dead")
+			}
+			return nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.False(t, c.Conn == net.Conn(dead))
+	require.Equal(t, int32(2), atomic.LoadInt32(&dials))
+	require.Nil(t, p.Release(c, nil))
+	<-p.Close()
+}
+
+func TestCheckOnReturnRejectsConnections(t *testing.T) {
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return &mockConn{}, nil
+		},
+		CheckOnReturn: func(c net.Conn) error {
+			return errors.New("unread data")
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c1, nil))
+	require.False(t, c1.IsHealthy())
+
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.False(t, c1.Conn == c2.Conn)
+	require.Equal(t, int32(2), atomic.LoadInt32(&dials))
+
+	// Stale leases are rejected without running the check
+	require.Equal(t, pool.ErrAlreadyReleased, p.Release(c1, nil))
+	require.Nil(t, p.Release(c2, nil))
+	<-p.Close()
+}
+
+func TestOnAcquireAndOnReturnPrepareConnections(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	var acquires int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+		OnAcquire: func(c net.Conn) error {
+			mu.Lock()
+			calls = append(calls, "acquire")
+			mu.Unlock()
+			if atomic.AddInt32(&acquires, 1) == 2 {
+				return errors.New("prompt not cleared")
+			}
+			return nil
+		},
+		OnReturn: func(c net.Conn) error {
+			mu.Lock()
+			calls = append(calls, "return")
+			mu.Unlock()
+			return nil
+		},
+		CheckOnReturn: func(c net.Conn) error {
+			mu.Lock()
+			calls = append(calls, "check")
+			mu.Unlock()
+			return nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	c1, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c1, nil))
+
+	// The second acquire fails so that connection is replaced with a new one
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.False(t, c1.Conn == c2.Conn)
+	require.Nil(t, p.Release(c2, errors.New("connection reset")))
+	<-p.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"acquire", "return", "check", "acquire", "acquire"}, calls)
+}
+
+func TestPanickingNewConnectionIsRetried(t *testing.T) {
+	var dials int32
+	dialErrs := make(chan error, 10)
+	p, err := pool.NewPool(pool.Config{
+		Size:          1,
+		RetryDuration: time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				panic("firmware quirk")
+			}
+			return &mockConn{}, nil
+		},
+		OnDialError: func(attempt int, err error) {
+			dialErrs <- err
+		},
+	})
+	require.Nil(t, err)
+
+	result := <-p.InitContext(context.Background())
+	require.Equal(t, 1, result.Established)
+	require.EqualError(t, <-dialErrs, "NewConnection panicked: firmware quirk")
+	<-p.Close()
+}
+
+func TestPanickingWrapConnClosesTheConnection(t *testing.T) {
+	var wraps int32
+	closed := make(chan struct{}, 10)
+	dialErrs := make(chan error, 10)
+	p, err := pool.NewPool(pool.Config{
+		Size:          1,
+		RetryDuration: time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{CloseCalled: func(*mockConn) { closed <- struct{}{} }}, nil
+		},
+		WrapConn: []func(net.Conn) net.Conn{
+			func(c net.Conn) net.Conn { return c },
+			func(c net.Conn) net.Conn {
+				if atomic.AddInt32(&wraps, 1) == 1 {
+					panic("bad framing")
+				}
+				return c
+			},
+		},
+		OnDialError: func(attempt int, err error) {
+			dialErrs <- err
+		},
+	})
+	require.Nil(t, err)
+
+	result := <-p.InitContext(context.Background())
+	require.Equal(t, 1, result.Established)
+	require.EqualError(t, <-dialErrs, "WrapConn[1] panicked: bad framing")
+	require.Len(t, closed, 1)
+	<-p.Close()
+}
+
+func TestStatsCountsDialsTimeoutsAndWaits(t *testing.T) {
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Size:          1,
+		RetryDuration: time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				return nil, errors.New("connection refused")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	s := p.Stats()
+	require.Equal(t, int64(2), s.Dials)
+	require.Equal(t, int64(1), s.DialFailures)
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+
+	waited := make(chan error)
+	go func() {
+		c, err := p.Get(time.Second, pool.GetOptions{})
+		if err == nil {
+			err = p.Release(c, nil)
+		}
+		waited <- err
+	}()
+	require.Eventually(t, func() bool { return p.Stats().Waiters == 1 }, time.Second, time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	require.Nil(t, p.Release(c, nil))
+	require.Nil(t, <-waited)
+	require.True(t, p.Stats().WaitTime >= 10*time.Millisecond)
+
+	c, err = p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	_, err = p.Get(time.Millisecond, pool.GetOptions{})
+	require.ErrorIs(t, err, pool.ErrTimeout)
+	s = p.Stats()
+	require.Equal(t, int64(1), s.Timeouts)
+	require.Equal(t, 0, s.Waiters)
+	require.Nil(t, p.Release(c, nil))
+	<-p.Close()
+}
+
+func TestConnectionIdentity(t *testing.T) {
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Size:          2,
+		RetryDuration: time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) <= 2 {
+				return nil, errors.New("connection refused")
+			}
+			return &mockConn{}, nil
+		},
+		InitConcurrency: 1,
+	})
+	require.Nil(t, err)
+	events := p.Subscribe()
+	<-p.Init()
+
+	c1, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.NotEqual(t, c1.ID(), c2.ID())
+	require.Equal(t, 4, c1.DialAttempts()+c2.DialAttempts())
+	require.False(t, c1.CreatedAt().IsZero())
+	require.False(t, c1.LastUsedAt().Before(c1.CreatedAt()))
+
+	// A new lease on the same connection keeps its ID
+	id := c1.ID()
+	require.Nil(t, p.Release(c1, nil))
+	require.Nil(t, p.Release(c2, errors.New("connection reset")))
+	c1, err = p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Equal(t, id, c1.ID())
+
+	for e := range events {
+		if e.Type == pool.ConnEvicted {
+			require.Equal(t, c2.ID(), e.ConnID)
+			break
+		}
+	}
+	require.Nil(t, p.Release(c1, nil))
+	<-p.Close()
+}
+
+func TestOnGetAndOnReleaseHooks(t *testing.T) {
+	type release struct {
+		held time.Duration
+		bad  bool
+	}
+	var mu sync.Mutex
+	var gets []bool
+	var releases []release
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+		OnGet: func(waited time.Duration, dialed bool) {
+			mu.Lock()
+			gets = append(gets, dialed)
+			mu.Unlock()
+		},
+		OnRelease: func(held time.Duration, bad bool) {
+			mu.Lock()
+			releases = append(releases, release{held, bad})
+			mu.Unlock()
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	time.Sleep(10 * time.Millisecond)
+	require.Nil(t, p.Release(c, nil))
+
+	c, err = p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, errors.New("connection reset")))
+
+	// Releasing a lease twice doesn't call the hook again
+	require.Equal(t, pool.ErrAlreadyReleased, p.Release(c, nil))
+	<-p.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []bool{false, false}, gets)
+	require.Len(t, releases, 2)
+	require.True(t, releases[0].held >= 10*time.Millisecond)
+	require.False(t, releases[0].bad)
+	require.True(t, releases[1].bad)
+}
+
+func TestStatsJSON(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size:           1,
+		LatencyBuckets: []time.Duration{time.Second},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	b, err := json.Marshal(p.Stats())
+	require.Nil(t, err)
+	var fields map[string]json.RawMessage
+	require.Nil(t, json.Unmarshal(b, &fields))
+	for _, name := range []string{"size", "idle", "in_use", "standby", "waiters", "dials", "dial_failures", "timeouts", "wait_time_ns", "wait_times", "dial_times"} {
+		require.Contains(t, fields, name)
+	}
+	require.NotContains(t, fields, "addresses")
+	require.JSONEq(t, `1`, string(fields["dials"]))
+	require.JSONEq(t, `[1000000000]`, string(mustField(t, fields["dial_times"], "buckets_ns")))
+}
+
+func mustField(t *testing.T, b json.RawMessage, name string) json.RawMessage {
+	var fields map[string]json.RawMessage
+	require.Nil(t, json.Unmarshal(b, &fields))
+	require.Contains(t, fields, name)
+	return fields[name]
+}
+
+func TestOnCloseReportsWhyConnectionsWereClosed(t *testing.T) {
+	var mu sync.Mutex
+	var reasons []string
+	p, err := pool.NewPool(pool.Config{
+		Size: 2,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+		OnClose: func(c net.Conn, reason string) {
+			require.NotNil(t, c)
+			mu.Lock()
+			reasons = append(reasons, reason)
+			mu.Unlock()
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, errors.New("connection reset")))
+	c, err = p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	c, err = p.Replace(c)
+	require.Nil(t, err)
+	require.Nil(t, c.Close())
+	<-p.Init()
+	<-p.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"bad connection", "replaced", "pool closed", "pool closed"}, reasons)
+}
@@ -0,0 +1,61 @@
+package pool
+
+import "sync"
+
+// stateChange is a connection state change waiting to be passed to Config.OnConnStateChange
+type stateChange struct {
+	id       uint64
+	from, to ConnState
+}
+
+// stateChanges queues the state changes for Config.OnConnStateChange. They are delivered in
+// order from a single goroutine, started when there are changes to deliver, so the hook is
+// never called with p.mu held and can't hold up the pool
+type stateChanges struct {
+	mu      sync.Mutex
+	queue   []stateChange
+	running bool
+}
+
+// setState moves the connection to a new state, queueing the change for
+// Config.OnConnStateChange. Bad connections can only be closed and closed ones stay closed
+func (pc *pooledConn) setState(to ConnState) {
+	p := pc.owner
+	if p == nil || p.Config.OnConnStateChange == nil {
+		return
+	}
+
+	p.changes.mu.Lock()
+	defer p.changes.mu.Unlock()
+	from := pc.state
+	if from == to || from == StateClosed || (from == StateBad && to != StateClosed) {
+		return
+	}
+	pc.state = to
+	p.changes.queue = append(p.changes.queue, stateChange{id: pc.id, from: from, to: to})
+	if !p.changes.running {
+		p.changes.running = true
+		p.goLabeled("state-changes", p.deliverStateChanges)
+	}
+}
+
+// deliverStateChanges calls Config.OnConnStateChange for the queued changes until there are
+// none left
+func (p *ConnectionPool) deliverStateChanges() {
+	for {
+		p.changes.mu.Lock()
+		queue := p.changes.queue
+		p.changes.queue = nil
+		if len(queue) == 0 {
+			p.changes.running = false
+		}
+		p.changes.mu.Unlock()
+		if len(queue) == 0 {
+			return
+		}
+
+		for _, c := range queue {
+			p.safely("OnConnStateChange", func() { p.Config.OnConnStateChange(c.id, c.from, c.to) })
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package pool
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// deadlineConn sets a deadline before every read and write, see DeadlineConn
+type deadlineConn struct {
+	net.Conn
+	readTimeout, writeTimeout time.Duration
+
+	// readDeadline and writeDeadline are the deadlines set by the caller, they are restored
+	// after each read and write
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+// DeadlineConn wraps c so every Read fails if it takes longer than readTimeout and every
+// Write if it takes longer than writeTimeout, instead of hanging forever on a device that has
+// stalled. A timeout of 0 leaves that direction alone. Deadlines the caller sets still apply
+// if they are sooner, and are put back after each call. The pool wraps its connections with
+// it when Config.ReadTimeout or Config.WriteTimeout is set
+func DeadlineConn(c net.Conn, readTimeout, writeTimeout time.Duration) net.Conn {
+	return &deadlineConn{Conn: c, readTimeout: readTimeout, writeTimeout: writeTimeout}
+}
+
+func (c *deadlineConn) Read(b []byte) (int, error) {
+	if c.readTimeout <= 0 {
+		return c.Conn.Read(b)
+	}
+	c.mu.Lock()
+	caller := c.readDeadline
+	c.mu.Unlock()
+	if err := c.Conn.SetReadDeadline(sooner(caller, c.readTimeout)); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Read(b)
+	c.Conn.SetReadDeadline(caller)
+	return n, err
+}
+
+func (c *deadlineConn) Write(b []byte) (int, error) {
+	if c.writeTimeout <= 0 {
+		return c.Conn.Write(b)
+	}
+	c.mu.Lock()
+	caller := c.writeDeadline
+	c.mu.Unlock()
+	if err := c.Conn.SetWriteDeadline(sooner(caller, c.writeTimeout)); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Write(b)
+	c.Conn.SetWriteDeadline(caller)
+	return n, err
+}
+
+func (c *deadlineConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline, c.writeDeadline = t, t
+	c.mu.Unlock()
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *deadlineConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.readDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.writeDeadline = t
+	c.mu.Unlock()
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// NetConn returns the wrapped connection
+func (c *deadlineConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// sooner returns the deadline timeout from now, or deadline if it is set and sooner
+func sooner(deadline time.Time, timeout time.Duration) time.Time {
+	t := time.Now().Add(timeout)
+	if !deadline.IsZero() && deadline.Before(t) {
+		return deadline
+	}
+	return t
+}
@@ -0,0 +1,72 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadAndWriteTimeouts(t *testing.T) {
+	var peers []net.Conn
+	p, err := pool.NewPool(pool.Config{
+		Size:         1,
+		ReadTimeout:  20 * time.Millisecond,
+		WriteTimeout: 20 * time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			c, peer := net.Pipe()
+			peers = append(peers, peer)
+			return c, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() {
+		<-p.Close()
+		for _, peer := range peers {
+			peer.Close()
+		}
+	}()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	defer c.Close()
+
+	// Nothing is ever sent or read on the other end so both calls stall
+	start := time.Now()
+	_, err = c.Read(make([]byte, 1))
+	require.True(t, errors.Is(err, os.ErrDeadlineExceeded), err)
+	_, err = c.Write([]byte("on\r\n"))
+	require.True(t, errors.Is(err, os.ErrDeadlineExceeded), err)
+	require.True(t, time.Since(start) < time.Second)
+
+	// A sooner deadline set by the caller still applies
+	require.Nil(t, c.SetReadDeadline(time.Now().Add(time.Millisecond)))
+	start = time.Now()
+	_, err = c.Read(make([]byte, 1))
+	require.True(t, errors.Is(err, os.ErrDeadlineExceeded), err)
+	require.True(t, time.Since(start) < 20*time.Millisecond)
+}
+
+func TestDeadlineConnClearsItsDeadlineAfterEachRead(t *testing.T) {
+	c, peer := net.Pipe()
+	defer peer.Close()
+	dc := pool.DeadlineConn(c, 10*time.Millisecond, 0)
+	defer dc.Close()
+
+	go peer.Write([]byte("x"))
+	_, err := dc.Read(make([]byte, 1))
+	require.Nil(t, err)
+
+	// Reading the underlying connection directly isn't limited by the timeout any more
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		peer.Write([]byte("y"))
+	}()
+	_, err = c.Read(make([]byte, 1))
+	require.Nil(t, err)
+}
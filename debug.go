@@ -0,0 +1,79 @@
+package pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// poolReport is what Handler reports for each pool
+type poolReport struct {
+	Health HealthStatus `json:"health"`
+	Stats  Stats        `json:"stats"`
+	State  PoolState    `json:"state"`
+}
+
+// Handler returns an http.Handler that reports the current state of the pools, their health,
+// waiters and every open connection, much like net/http/pprof does for the program. By
+// default it writes a plain text report, with ?format=json it writes a JSON array with an
+// object for each pool. ?name= limits the report to the pools with that Config.Name. Mount
+// it wherever suits, for example
+//
+//	http.Handle("/debug/pools", pool.Handler(thermostat, lights))
+func Handler(pools ...*ConnectionPool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("name")
+		reports := make([]poolReport, 0, len(pools))
+		for _, p := range pools {
+			if name != "" && p.Config.Name != name {
+				continue
+			}
+			reports = append(reports, poolReport{Health: p.Health(), Stats: p.Stats(), State: p.Dump()})
+		}
+
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(reports)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for i, report := range reports {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			fmt.Fprint(w, report.State)
+			h := report.Health
+			fmt.Fprintf(w, "  health: %s live=%d failed=%d", h.State, h.Live, h.Failed)
+			if h.LastDialErr != nil {
+				fmt.Fprintf(w, " last-dial-error=%q", h.LastDialErr)
+			}
+			s := report.Stats
+			fmt.Fprintf(w, "\n  stats: idle=%d in-use=%d standby=%d dials=%d dial-failures=%d timeouts=%d\n",
+				s.Idle, s.InUse, s.Standby, s.Dials, s.DialFailures, s.Timeouts)
+		}
+	})
+}
+
+// ReadyHandler returns an http.Handler for liveness or readiness probes, such as a
+// Kubernetes readinessProbe. It responds 200 OK if all of the pools are Ready and 503
+// Service Unavailable, listing the names of the pools that aren't, otherwise
+func ReadyHandler(pools ...*ConnectionPool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notReady []string
+		for _, p := range pools {
+			if !p.Ready() {
+				notReady = append(notReady, p.Config.Name)
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(notReady) > 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, "not ready: %s\n", strings.Join(notReady, ", "))
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+}
@@ -0,0 +1,106 @@
+package pool_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerReportsPools(t *testing.T) {
+	newPool := func(name string) *pool.ConnectionPool {
+		p, err := pool.NewPool(pool.Config{
+			Name: name,
+			Size: 2,
+			NewConnection: func(cfg pool.Config) (net.Conn, error) {
+				return &mockConn{}, nil
+			},
+		})
+		require.Nil(t, err)
+		<-p.Init()
+		return p
+	}
+	lights, thermostat := newPool("lights"), newPool("thermostat")
+	defer func() { <-lights.Close() }()
+	defer func() { <-thermostat.Close() }()
+
+	c, err := lights.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	defer c.Close()
+
+	h := pool.Handler(lights, thermostat)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pools", nil))
+	body := rec.Body.String()
+	require.Contains(t, body, `pool "lights": size=2 open=2 waiters=0`)
+	require.Contains(t, body, `pool "thermostat"`)
+	require.Contains(t, body, "checked-out")
+	require.Contains(t, body, "health: healthy live=2 failed=0")
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pools?format=json&name=lights", nil))
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var reports []struct {
+		Health pool.HealthStatus
+		Stats  pool.Stats
+		State  struct {
+			Name  string
+			Conns []struct {
+				State string
+			}
+		}
+	}
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &reports))
+	require.Len(t, reports, 1)
+	require.Equal(t, "lights", reports[0].State.Name)
+	require.Equal(t, pool.Healthy, reports[0].Health.State)
+	require.Equal(t, 1, reports[0].Stats.InUse)
+	require.Len(t, reports[0].State.Conns, 2)
+}
+
+func TestReadyHandler(t *testing.T) {
+	var fail int32
+	p, err := pool.NewPool(pool.Config{
+		Name:          "lights",
+		Size:          1,
+		RetryDuration: time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.LoadInt32(&fail) == 1 {
+				return nil, errors.New("connection refused")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	require.False(t, p.Ready())
+	<-p.Init()
+	require.True(t, p.Ready())
+	require.True(t, pool.AllReady(p))
+
+	h := pool.ReadyHandler(p)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	require.Equal(t, 200, rec.Code)
+
+	// Once the only connection goes bad and can't be replaced the pool isn't ready
+	atomic.StoreInt32(&fail, 1)
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, errors.New("connection reset")))
+	require.False(t, p.Ready())
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/readyz", nil))
+	require.Equal(t, 503, rec.Code)
+	require.Equal(t, "not ready: lights\n", rec.Body.String())
+
+	atomic.StoreInt32(&fail, 0)
+	require.Eventually(t, p.Ready, time.Second, time.Millisecond)
+	<-p.Close()
+	require.False(t, p.Ready())
+}
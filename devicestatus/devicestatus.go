@@ -0,0 +1,109 @@
+// Package devicestatus turns a pool's connection events into changes of the device's status,
+// unreachable, reconnected or exhausted, and hands them to a Publisher so automations can
+// react to devices going offline and coming back. It doesn't depend on any particular event
+// bus or message broker, wrap whatever sends the events in a PublisherFunc to connect the two
+package devicestatus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+)
+
+// Kind says what happened to the device behind the pool
+type Kind int
+
+const (
+	// DeviceUnreachable is published when the pool has lost all of its connections and
+	// can't make new ones
+	DeviceUnreachable Kind = iota
+
+	// DeviceReconnected is published when the pool connects to the device again after it
+	// was unreachable
+	DeviceReconnected
+
+	// PoolExhausted is published when a caller has to wait because every connection is in use
+	PoolExhausted
+)
+
+func (k Kind) String() string {
+	switch k {
+	case DeviceUnreachable:
+		return "DeviceUnreachable"
+	case DeviceReconnected:
+		return "DeviceReconnected"
+	case PoolExhausted:
+		return "PoolExhausted"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Event is a change of the device's status, passed to the Publisher
+type Event struct {
+	Kind    Kind
+	Pool    string
+	Address string
+	Time    time.Time
+
+	// Err is the last connection error for DeviceUnreachable events
+	Err error
+}
+
+func (e Event) String() string {
+	s := fmt.Sprintf("pool %q: %s", e.Pool, e.Kind)
+	if e.Address != "" {
+		s += " " + e.Address
+	}
+	if e.Err != nil {
+		s += ": " + e.Err.Error()
+	}
+	return s
+}
+
+// Publisher receives the status changes, it is usually a thin wrapper around an event bus
+// or message broker client
+type Publisher interface {
+	Publish(Event)
+}
+
+// PublisherFunc lets an ordinary function, such as a closure calling a bus client, be used
+// as a Publisher
+type PublisherFunc func(Event)
+
+// Publish calls f(e)
+func (f PublisherFunc) Publish(e Event) {
+	f(e)
+}
+
+// Forward publishes the pool's connectivity events until the pool is closed or the returned
+// stop function is called. Publish is called from a single goroutine owned by Forward
+func Forward(p *pool.ConnectionPool, pub Publisher) (stop func()) {
+	events := p.Subscribe()
+	go func() {
+		unreachable := false
+		for e := range events {
+			switch e.Type {
+			case pool.ConnFailed:
+				if !unreachable && open(p) == 0 {
+					unreachable = true
+					pub.Publish(Event{Kind: DeviceUnreachable, Pool: e.Pool, Address: e.Address, Time: e.Time, Err: e.Err})
+				}
+			case pool.ConnEstablished:
+				if unreachable {
+					unreachable = false
+					pub.Publish(Event{Kind: DeviceReconnected, Pool: e.Pool, Address: e.Address, Time: e.Time})
+				}
+			case pool.PoolExhausted:
+				pub.Publish(Event{Kind: PoolExhausted, Pool: e.Pool, Time: e.Time})
+			}
+		}
+	}()
+	return func() { p.Unsubscribe(events) }
+}
+
+// open returns the number of connections the pool has open
+func open(p *pool.ConnectionPool) int {
+	s := p.Stats()
+	return s.Idle + s.InUse
+}
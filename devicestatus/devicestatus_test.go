@@ -0,0 +1,63 @@
+package devicestatus_test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/go-home-iot/connection-pool/devicestatus"
+	"github.com/stretchr/testify/require"
+)
+
+type mockConn struct {
+	net.Conn
+}
+
+func (c *mockConn) Close() error {
+	return nil
+}
+
+func TestForwardPublishesConnectivityChanges(t *testing.T) {
+	var mu sync.Mutex
+	up := true
+	p, err := pool.NewPool(pool.Config{
+		Name:          "lights",
+		Address:       "10.0.0.5:23",
+		Size:          1,
+		RetryDuration: 5 * time.Millisecond,
+		NewConnection: func(pool.Config) (net.Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !up {
+				return nil, errors.New("no route to host")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	published := make(chan devicestatus.Event, 10)
+	stop := devicestatus.Forward(p, devicestatus.PublisherFunc(func(e devicestatus.Event) { published <- e }))
+	defer stop()
+
+	mu.Lock()
+	up = false
+	mu.Unlock()
+	p.InvalidateAll()
+
+	e := <-published
+	require.Equal(t, devicestatus.DeviceUnreachable, e.Kind)
+	require.Equal(t, `pool "lights": DeviceUnreachable 10.0.0.5:23: no route to host`, e.String())
+
+	mu.Lock()
+	up = true
+	mu.Unlock()
+	e = <-published
+	require.Equal(t, devicestatus.DeviceReconnected, e.Kind)
+	require.Equal(t, "lights", e.Pool)
+	<-p.Close()
+}
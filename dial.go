@@ -0,0 +1,122 @@
+package pool
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// waitDialTurn blocks until a connection attempt is allowed to start, taking one of the
+// Config.InitConcurrency slots and waiting for Config.DialDelay since the previous attempt
+// started. It returns false, without holding a slot, if the context is done or stop is
+// closed first. dialFinished must be called once the attempt has finished
+func (p *ConnectionPool) waitDialTurn(ctx context.Context, stop chan struct{}) bool {
+	if p.dialSlots != nil {
+		select {
+		case p.dialSlots <- struct{}{}:
+		case <-stop:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	if p.Config.DialDelay > 0 {
+		p.mu.Lock()
+		now := time.Now()
+		if p.nextDial.Before(now) {
+			p.nextDial = now
+		}
+		wait := p.nextDial.Sub(now)
+		p.nextDial = p.nextDial.Add(p.Config.DialDelay)
+		p.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-stop:
+			p.dialFinished()
+			return false
+		case <-ctx.Done():
+			p.dialFinished()
+			return false
+		}
+	}
+	return true
+}
+
+// dialFinished gives back the slot taken by waitDialTurn
+func (p *ConnectionPool) dialFinished() {
+	if p.dialSlots != nil {
+		<-p.dialSlots
+	}
+}
+
+// RetryBudget is a token bucket shared by every connection attempt in the pool. Each retry of
+// a failed attempt takes a token, the bucket holds up to Burst tokens and gains PerMinute
+// tokens a minute, so a flapping device is retried at most that often however large the
+// pool is. Retries wait for a token when the bucket is empty, first attempts don't need one
+type RetryBudget struct {
+	PerMinute int
+	Burst     int
+}
+
+// waitRetryBudget blocks until there is a token in Config.RetryBudget for a retry, taking it.
+// It returns false if the context is done or stop is closed first
+func (p *ConnectionPool) waitRetryBudget(ctx context.Context, stop chan struct{}) bool {
+	b := p.Config.RetryBudget
+	if b == nil {
+		return true
+	}
+
+	for {
+		p.mu.Lock()
+		now := time.Now()
+		if p.retryRefilled.IsZero() {
+			p.retryTokens = float64(b.Burst)
+		} else {
+			p.retryTokens += now.Sub(p.retryRefilled).Minutes() * float64(b.PerMinute)
+			if p.retryTokens > float64(b.Burst) {
+				p.retryTokens = float64(b.Burst)
+			}
+		}
+		p.retryRefilled = now
+		if p.retryTokens >= 1 {
+			p.retryTokens--
+			p.mu.Unlock()
+			return true
+		}
+		wait := time.Duration((1 - p.retryTokens) / float64(b.PerMinute) * float64(time.Minute))
+		p.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return false
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+	}
+}
+
+// waitJitter waits for a random time up to Config.ReconnectJitter. It returns false if the
+// context is done or stop is closed first
+func (p *ConnectionPool) waitJitter(ctx context.Context, stop chan struct{}) bool {
+	if p.Config.ReconnectJitter <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(p.Config.ReconnectJitter))))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+	case <-ctx.Done():
+	}
+	return false
+}
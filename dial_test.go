@@ -0,0 +1,247 @@
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitConcurrencyLimitsSimultaneousDials(t *testing.T) {
+	var dialing, most int32
+	p, err := pool.NewPool(pool.Config{
+		Size:            6,
+		InitConcurrency: 2,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			n := atomic.AddInt32(&dialing, 1)
+			defer atomic.AddInt32(&dialing, -1)
+			for {
+				m := atomic.LoadInt32(&most)
+				if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+	require.Equal(t, 6, p.Stats().Idle)
+	require.Equal(t, int32(2), atomic.LoadInt32(&most))
+	<-p.Close()
+}
+
+func TestDialDelayStaggersDials(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size:      3,
+		DialDelay: 10 * time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	start := time.Now()
+	done := p.Init()
+	<-done
+	require.True(t, time.Since(start) >= 20*time.Millisecond)
+	<-p.Close()
+}
+
+func TestRetryBudgetLimitsRetriesAcrossThePool(t *testing.T) {
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Size:          3,
+		RetryDuration: time.Millisecond,
+		RetryBudget:   &pool.RetryBudget{PerMinute: 1, Burst: 2},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return nil, errors.New("device offline")
+		},
+	})
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	<-p.InitContext(ctx)
+
+	// Three first attempts and the two retries the budget allows
+	require.Equal(t, int32(5), atomic.LoadInt32(&dials))
+	<-p.Close()
+}
+
+func TestReconnectJitterSpreadsOutReplacements(t *testing.T) {
+	dialed := make(chan time.Time, 20)
+	p, err := pool.NewPool(pool.Config{
+		Size:            5,
+		ReconnectJitter: 100 * time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			dialed <- time.Now()
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+	for i := 0; i < 5; i++ {
+		<-dialed
+	}
+
+	// Init isn't delayed, replacing every connection at once is
+	start := time.Now()
+	p.InvalidateAll()
+	var first, last time.Time
+	for i := 0; i < 5; i++ {
+		d := <-dialed
+		if first.IsZero() || d.Before(first) {
+			first = d
+		}
+		if d.After(last) {
+			last = d
+		}
+	}
+	require.True(t, last.Sub(start) < time.Second)
+	require.True(t, last.Sub(first) > time.Millisecond)
+	<-p.Close()
+}
+
+func TestFatalDialErrorGivesUpStraightAway(t *testing.T) {
+	var dials int32
+	errRejected := errors.New("credentials rejected")
+	p, err := pool.NewPool(pool.Config{
+		Size:          1,
+		RetryDuration: time.Millisecond,
+		IsFatal: func(err error) bool {
+			return err == errRejected
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return nil, errRejected
+		},
+	})
+	require.Nil(t, err)
+
+	result := <-p.InitContext(context.Background())
+	require.True(t, errors.Is(result.Err, pool.ErrDialFailed))
+	require.Equal(t, int32(1), atomic.LoadInt32(&dials))
+	<-p.Close()
+}
+
+func TestFatalDialErrorFailsOverStraightAway(t *testing.T) {
+	errRejected := errors.New("credentials rejected")
+	p, err := pool.NewPool(pool.Config{
+		Size:          1,
+		Address:       "10.0.0.5:23",
+		RetryDuration: time.Millisecond,
+		IsFatal: func(err error) bool {
+			return err == errRejected
+		},
+		Failover: &pool.Failover{
+			Addresses:     []string{"10.0.0.6:23"},
+			Failures:      100,
+			ProbeInterval: time.Hour,
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if cfg.Address == "10.0.0.5:23" {
+				return nil, errRejected
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	result := <-p.InitContext(context.Background())
+	require.Nil(t, result.Err)
+	require.Equal(t, 1, result.Established)
+	require.Equal(t, "10.0.0.6:23", p.ActiveAddress())
+	<-p.Close()
+}
+
+func TestOnConnectRunsOnEveryNewConnection(t *testing.T) {
+	var handshakes, closed int32
+	p, err := pool.NewPool(pool.Config{
+		Size:          1,
+		RetryDuration: time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{CloseCalled: func(*mockConn) { atomic.AddInt32(&closed, 1) }}, nil
+		},
+		OnConnect: func(c net.Conn) error {
+			if atomic.AddInt32(&handshakes, 1) == 1 {
+				return errors.New("login rejected")
+			}
+			return nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	// The first handshake failed so that connection was closed and another one made
+	require.Equal(t, int32(2), atomic.LoadInt32(&handshakes))
+	require.Equal(t, int32(1), atomic.LoadInt32(&closed))
+	s := p.Stats()
+	require.Equal(t, int64(2), s.Dials)
+	require.Equal(t, int64(1), s.DialFailures)
+	require.EqualError(t, p.Health().LastDialErr, "connection handshake failed: login rejected")
+
+	// Replacements made in the background are handshaken too
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, errors.New("connection reset")))
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&handshakes) == 3 }, time.Second, time.Millisecond)
+	<-p.Close()
+}
+
+type taggedConn struct {
+	net.Conn
+	tag string
+}
+
+func TestWrapConnWrapsEveryNewConnection(t *testing.T) {
+	wrap := func(tag string) func(net.Conn) net.Conn {
+		return func(c net.Conn) net.Conn { return &taggedConn{Conn: c, tag: tag} }
+	}
+	var connected net.Conn
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+		WrapConn: []func(net.Conn) net.Conn{wrap("inner"), wrap("outer")},
+		OnConnect: func(c net.Conn) error {
+			connected = c
+			return nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	defer c.Close()
+
+	outer, ok := c.Conn.(*taggedConn)
+	require.True(t, ok)
+	require.Equal(t, "outer", outer.tag)
+	require.Equal(t, "inner", outer.Conn.(*taggedConn).tag)
+	require.IsType(t, &mockConn{}, outer.Conn.(*taggedConn).Conn)
+	require.True(t, connected == c.Conn)
+}
+
+func TestWrapConnMustNotContainNil(t *testing.T) {
+	_, err := pool.NewPool(pool.Config{
+		Size:     1,
+		Address:  "10.0.0.5:23",
+		WrapConn: []func(net.Conn) net.Conn{nil},
+	})
+	require.True(t, errors.Is(err, pool.ErrInvalidConfig))
+}
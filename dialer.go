@@ -0,0 +1,38 @@
+package pool
+
+import (
+	"fmt"
+	"net"
+)
+
+// netDialer returns the net.Dialer DefaultNewConnection dials with
+func netDialer(c Config) (*net.Dialer, error) {
+	d := &net.Dialer{
+		Timeout:       c.DialTimeout,
+		KeepAlive:     c.KeepAlive,
+		FallbackDelay: c.FallbackDelay,
+	}
+	if c.LocalAddr != "" {
+		addr, err := localAddr(c.LocalAddr)
+		if err != nil {
+			return nil, err
+		}
+		d.LocalAddr = addr
+	}
+	return d, nil
+}
+
+// localAddr parses Config.LocalAddr, an IP address with or without a port
+func localAddr(s string) (*net.TCPAddr, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		return &net.TCPAddr{IP: ip}, nil
+	}
+	host, port, err := net.SplitHostPort(s)
+	if err != nil {
+		return nil, fmt.Errorf("LocalAddr must be an IP address, with or without a port, got %q", s)
+	}
+	if net.ParseIP(host) == nil {
+		return nil, fmt.Errorf("LocalAddr must be an IP address, got %q", host)
+	}
+	return net.ResolveTCPAddr("tcp", net.JoinHostPort(host, port))
+}
@@ -0,0 +1,76 @@
+package pool_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultNewConnectionBindsLocalAddr(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			host, _, _ := net.SplitHostPort(c.RemoteAddr().String())
+			fmt.Fprintln(c, host)
+			c.Close()
+		}
+	}()
+
+	c, err := pool.DefaultNewConnection(pool.Config{Address: l.Addr().String(), LocalAddr: "127.0.0.2"})
+	require.Nil(t, err)
+	defer c.Close()
+	from, err := bufio.NewReader(c).ReadString('\n')
+	require.Nil(t, err)
+	require.Equal(t, "127.0.0.2\n", from)
+}
+
+func TestDialTimeoutCoversTheTLSHandshake(t *testing.T) {
+	// The device accepts the connection but never answers the handshake
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	start := time.Now()
+	_, err = pool.DefaultNewConnection(pool.Config{Address: "tls://" + l.Addr().String(), DialTimeout: 50 * time.Millisecond})
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+	require.Less(t, time.Since(start), time.Second)
+}
+
+func TestNewPoolRejectsInvalidDialSettings(t *testing.T) {
+	configs := []pool.Config{
+		{Size: 1, Address: "bridge:23", DialTimeout: -time.Second},
+		{Size: 1, Address: "bridge:23", LocalAddr: "hub"},
+		{Size: 1, Address: "bridge:23", LocalAddr: "hub:0"},
+		{Size: 1, Address: "bridge:23", TCPReceiveBuffer: -1},
+		{Size: 1, Address: "bridge:23", KeepAlive: -1, KeepAliveCount: 3},
+	}
+	for _, cfg := range configs {
+		_, err := pool.NewPool(cfg)
+		require.ErrorIs(t, err, pool.ErrInvalidConfig)
+	}
+
+	_, err := pool.NewPool(pool.Config{Size: 1, Address: "bridge:23", LocalAddr: "192.168.1.5:0"})
+	require.Nil(t, err)
+}
@@ -0,0 +1,61 @@
+package pool
+
+import "context"
+
+// Drain stops the pool handing out connections, Get calls return ErrDraining until it has
+// finished, then waits for every checked out connection to be released and closes all of the
+// connections. If the context is done first the connections that are still checked out are
+// forcibly closed and the context error is returned, releasing one of those afterwards
+// returns ErrAlreadyReleased. Unlike Close the pool can be used again once Drain returns, by
+// calling Init, which makes it useful for taking a device offline for maintenance
+func (p *ConnectionPool) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPoolClosed
+	}
+	if p.draining {
+		p.mu.Unlock()
+		return ErrDraining
+	}
+
+	p.draining = true
+	close(p.stop)
+	idle := p.idle
+	p.idle = nil
+	standby := p.takeStandbyLocked()
+	p.mu.Unlock()
+
+	for _, pc := range idle {
+		p.discard(pc, closeDrained)
+	}
+	for _, pc := range standby {
+		p.closeConn(pc, closeDrained)
+	}
+
+	var err error
+	p.mu.Lock()
+	for err == nil && (p.open > 0 || p.pending > 0) {
+		changed := p.changed
+		p.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		p.mu.Lock()
+	}
+	if err != nil {
+		p.closeInUseLocked()
+	}
+
+	p.draining = false
+	if !p.closed {
+		p.stop = make(chan struct{})
+		p.initDone = nil
+		p.initResult = InitResult{}
+	}
+	p.mu.Unlock()
+	return err
+}
@@ -0,0 +1,87 @@
+package pool_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrainWaitsForConnectionsAndAllowsInitAgain(t *testing.T) {
+	var dials, closes int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 2,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return &mockConn{CloseCalled: func(*mockConn) {
+				atomic.AddInt32(&closes, 1)
+			}}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+
+	drained := make(chan error, 1)
+	go func() {
+		drained <- p.Drain(context.Background())
+	}()
+
+	require.Eventually(t, func() bool {
+		_, err := p.TryGet()
+		return err == pool.ErrDraining
+	}, time.Second, time.Millisecond)
+	_, err = p.Get(time.Second, pool.GetOptions{})
+	require.Equal(t, pool.ErrDraining, err)
+
+	select {
+	case <-drained:
+		t.Fatal("drain finished with a connection still checked out")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	require.Nil(t, p.Release(c, nil))
+	require.Nil(t, <-drained)
+	require.Equal(t, int32(2), atomic.LoadInt32(&closes))
+	require.Equal(t, 0, p.Stats().Idle)
+
+	done = p.Init()
+	<-done
+	require.Equal(t, int32(4), atomic.LoadInt32(&dials))
+	require.Equal(t, 2, p.Stats().Idle)
+	<-p.Close()
+}
+
+func TestDrainForceClosesConnectionsWhenTheContextIsDone(t *testing.T) {
+	var closes int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{CloseCalled: func(*mockConn) {
+				atomic.AddInt32(&closes, 1)
+			}}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, p.Drain(ctx))
+	require.Equal(t, int32(1), atomic.LoadInt32(&closes))
+	require.Equal(t, pool.ErrAlreadyReleased, p.Release(c, nil))
+	<-p.Close()
+}
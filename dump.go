@@ -0,0 +1,166 @@
+package pool
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ConnState is the state of one of the pool's connections
+type ConnState int
+
+const (
+	// StateIdle means the connection is waiting in the pool to be used
+	StateIdle ConnState = iota
+
+	// StateInUse means the connection is checked out of the pool
+	StateInUse
+
+	// StateBad means the connection has been marked as bad and will be closed once released
+	StateBad
+
+	// StateDialing, StateEstablished and StateClosed are only passed to
+	// Config.OnConnStateChange. StateDialing is the state a connection is in before it is
+	// established, StateEstablished means it has just been created and not yet been put in
+	// the pool, or it is a standby connection, and StateClosed means it has been closed
+	StateDialing
+	StateEstablished
+	StateClosed
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateInUse:
+		return "checked-out"
+	case StateBad:
+		return "bad"
+	case StateDialing:
+		return "dialing"
+	case StateEstablished:
+		return "established"
+	case StateClosed:
+		return "closed"
+	}
+	return fmt.Sprintf("ConnState(%d)", int(s))
+}
+
+// MarshalText marshals the state as its name, so it appears as a string in JSON
+func (s ConnState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ConnInfo describes one of the connections in a PoolState
+type ConnInfo struct {
+	// ID is the connection's Connection.ID
+	ID    uint64    `json:"id"`
+	State ConnState `json:"state"`
+
+	// Age is how long ago the connection was opened
+	Age time.Duration `json:"age_ns"`
+
+	// LastUsed is the last time the connection was checked out, zero if it never has been
+	LastUsed time.Time `json:"last_used"`
+
+	// Address is the address the connection was made to
+	Address string `json:"address"`
+
+	// Uses is how many times the connection has been checked out
+	Uses int `json:"uses"`
+
+	// BytesRead and BytesWritten count the data read and written through the leases handed
+	// out for the connection. Errors counts the reads and writes that failed and the times
+	// the connection was released with an error
+	BytesRead    int64 `json:"bytes_read"`
+	BytesWritten int64 `json:"bytes_written"`
+	Errors       int64 `json:"errors"`
+}
+
+// PoolState is a detailed snapshot of the pool returned by Dump, intended for debugging
+type PoolState struct {
+	Name string `json:"name"`
+	Size int    `json:"size"`
+
+	// Waiters is the number of callers currently waiting for a connection
+	Waiters int `json:"waiters"`
+
+	// Conns has an entry for every open connection, oldest first
+	Conns []ConnInfo `json:"conns"`
+
+	// Audit is the recent Get and Release calls, oldest first, if Config.AuditSize is set
+	Audit []AuditEntry `json:"audit,omitempty"`
+}
+
+// Dump returns the current state of the pool and every connection in it, useful for
+// diagnosing why a pool is exhausted
+func (p *ConnectionPool) Dump() PoolState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conns := make([]*pooledConn, 0, len(p.conns))
+	for pc := range p.conns {
+		conns = append(conns, pc)
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].createdAt.Before(conns[j].createdAt)
+	})
+
+	now := time.Now()
+	state := PoolState{
+		Name:    p.Config.Name,
+		Size:    p.size,
+		Waiters: len(p.waiters),
+		Conns:   make([]ConnInfo, len(conns)),
+		Audit:   p.auditTrailLocked(),
+	}
+	for i, pc := range conns {
+		info := ConnInfo{
+			ID:       pc.id,
+			State:    StateIdle,
+			Age:      now.Sub(pc.createdAt),
+			LastUsed: pc.lastUsed,
+			Address:  pc.addr,
+			Uses:     pc.uses,
+
+			BytesRead:    atomic.LoadInt64(&pc.bytesRead),
+			BytesWritten: atomic.LoadInt64(&pc.bytesWritten),
+			Errors:       atomic.LoadInt64(&pc.errors),
+		}
+		if _, ok := p.inUse[pc]; ok {
+			info.State = StateInUse
+		}
+		if !pc.isHealthy() {
+			info.State = StateBad
+		}
+		state.Conns[i] = info
+	}
+	return state
+}
+
+// String formats the state as a human readable multi line report
+func (s PoolState) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "pool %q: size=%d open=%d waiters=%d\n", s.Name, s.Size, len(s.Conns), s.Waiters)
+	for i, c := range s.Conns {
+		lastUsed := "never"
+		if !c.LastUsed.IsZero() {
+			lastUsed = c.LastUsed.Format(time.RFC3339)
+		}
+		fmt.Fprintf(&b, "  conn %d: %s age=%s last-used=%s uses=%d read=%d written=%d errors=%d\n",
+			i, c.State, c.Age.Round(time.Millisecond), lastUsed, c.Uses, c.BytesRead, c.BytesWritten, c.Errors)
+	}
+	if len(s.Audit) > 0 {
+		fmt.Fprintf(&b, "  recent operations:\n")
+	}
+	for _, e := range s.Audit {
+		fmt.Fprintf(&b, "    %s %s conn=%d duration=%s caller=%s", e.Time.Format(time.RFC3339Nano), e.Op, e.ConnID, e.Duration.Round(time.Microsecond), e.Caller)
+		if e.Err != nil {
+			fmt.Fprintf(&b, " error=%q", e.Err)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
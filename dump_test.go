@@ -0,0 +1,151 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpReportsConnectionStates(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Name: "bridge",
+		Size: 3,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	c2, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	c2.MarkBad()
+
+	state := p.Dump()
+	require.Equal(t, "bridge", state.Name)
+	require.Equal(t, 3, state.Size)
+	require.Equal(t, 0, state.Waiters)
+
+	counts := map[pool.ConnState]int{}
+	for _, c := range state.Conns {
+		counts[c.State]++
+		if c.State != pool.StateIdle {
+			require.False(t, c.LastUsed.IsZero())
+		}
+	}
+
+	// The bad connection is replaced straight away so there may be an extra idle one
+	require.Equal(t, 1, counts[pool.StateInUse])
+	require.Equal(t, 1, counts[pool.StateBad])
+	require.True(t, counts[pool.StateIdle] >= 1)
+
+	out := state.String()
+	require.True(t, strings.HasPrefix(out, `pool "bridge": size=3`))
+	require.Contains(t, out, "checked-out")
+	require.Contains(t, out, "bad")
+
+	p.Release(c1, nil)
+	p.Release(c2, nil)
+}
+
+// echoConn is a connection that accepts every write and reads back a fixed reply
+type echoConn struct {
+	mockConn
+}
+
+func (c *echoConn) Read(b []byte) (int, error) {
+	return copy(b, "ok\r\n"), nil
+}
+
+func (c *echoConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func TestDumpReportsPerConnectionStatistics(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size:    1,
+		Address: "10.0.0.5:23",
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &echoConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	for i := 0; i < 2; i++ {
+		c, err := p.Get(time.Second, pool.GetOptions{})
+		require.Nil(t, err)
+		_, err = c.Write([]byte("status\r\n"))
+		require.Nil(t, err)
+		_, err = c.Read(make([]byte, 16))
+		require.Nil(t, err)
+		require.Nil(t, p.ReleaseWithError(c, errors.New("unknown command")))
+	}
+
+	state := p.Dump()
+	require.Len(t, state.Conns, 1)
+	info := state.Conns[0]
+	require.Equal(t, "10.0.0.5:23", info.Address)
+	require.Equal(t, 2, info.Uses)
+	require.Equal(t, int64(16), info.BytesWritten)
+	require.Equal(t, int64(8), info.BytesRead)
+	require.Equal(t, int64(0), info.Errors)
+	require.Contains(t, state.String(), "uses=2 read=8 written=16 errors=0")
+	<-p.Close()
+}
+
+func TestOnConnStateChangeReportsTransitions(t *testing.T) {
+	var mu sync.Mutex
+	changes := map[uint64][]string{}
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+		OnConnStateChange: func(id uint64, from, to pool.ConnState) {
+			mu.Lock()
+			changes[id] = append(changes[id], from.String()+"->"+to.String())
+			mu.Unlock()
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	id := c.ID()
+	require.Nil(t, c.Close())
+	c, err = p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	c.MarkBad()
+	require.Nil(t, c.Close())
+
+	want := []string{
+		"dialing->established",
+		"established->idle",
+		"idle->checked-out",
+		"checked-out->idle",
+		"idle->checked-out",
+		"checked-out->bad",
+		"bad->closed",
+	}
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(changes[id]) == len(want)
+	}, time.Second, time.Millisecond)
+	mu.Lock()
+	require.Equal(t, want, changes[id])
+	mu.Unlock()
+	<-p.Close()
+}
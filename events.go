@@ -0,0 +1,139 @@
+package pool
+
+import (
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// EventType says what happened in an Event
+type EventType int
+
+const (
+	// ConnEstablished is sent when a new connection has been made
+	ConnEstablished EventType = iota
+
+	// ConnFailed is sent when an attempt to make a connection fails, Event.Err holds the error
+	ConnFailed
+
+	// ConnEvicted is sent when a connection is thrown away, Event.Reason says why and
+	// Event.Err holds the error it was released with if there was one
+	ConnEvicted
+
+	// PoolExhausted is sent when a caller can't have a connection straight away because they
+	// are all in use and the pool can't make any more
+	PoolExhausted
+
+	// PoolClosed is sent when the pool is closed, it is the last event sent
+	PoolClosed
+)
+
+var eventNames = map[EventType]string{
+	ConnEstablished: "ConnEstablished",
+	ConnFailed:      "ConnFailed",
+	ConnEvicted:     "ConnEvicted",
+	PoolExhausted:   "PoolExhausted",
+	PoolClosed:      "PoolClosed",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventNames[t]; ok {
+		return name
+	}
+	return "EventType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// Event describes something that happened in the pool, see Subscribe
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// Pool is the Config.Name of the pool and Address is the address of the connection the
+	// event is about, empty for events about the whole pool
+	Pool    string
+	Address string
+
+	// ConnID is the Connection.ID of the connection for ConnEvicted events, zero otherwise
+	ConnID uint64
+
+	Reason string
+	Err    error
+
+	// Attrs are the Config.ContextAttrs of the GetContext call that found the pool exhausted,
+	// for PoolExhausted events
+	Attrs []slog.Attr
+}
+
+// EventBuffer is how many events each subscriber's channel holds
+const EventBuffer = 64
+
+// subscribers holds the channels returned by Subscribe, it has its own lock so events can be
+// sent whether or not p.mu is held
+type subscribers struct {
+	mu     sync.Mutex
+	chans  []chan Event
+	closed bool
+}
+
+// Subscribe returns a channel that receives the pool's events. The channel holds
+// EventBuffer events, if the receiver falls further behind than that newer events are
+// dropped rather than holding up the pool. The channel is closed after the PoolClosed event,
+// or when it is passed to Unsubscribe
+func (p *ConnectionPool) Subscribe() <-chan Event {
+	ch := make(chan Event, EventBuffer)
+	p.subs.mu.Lock()
+	defer p.subs.mu.Unlock()
+	if p.subs.closed {
+		close(ch)
+		return ch
+	}
+	p.subs.chans = append(p.subs.chans, ch)
+	return ch
+}
+
+// Unsubscribe stops sending events to a channel returned by Subscribe and closes it
+func (p *ConnectionPool) Unsubscribe(ch <-chan Event) {
+	p.subs.mu.Lock()
+	defer p.subs.mu.Unlock()
+	for i, c := range p.subs.chans {
+		if c == ch {
+			p.subs.chans = append(p.subs.chans[:i], p.subs.chans[i+1:]...)
+			close(c)
+			return
+		}
+	}
+}
+
+// exhaustedLocked sends a PoolExhausted event if every connection is in use and the pool
+// can't make any more, p.mu must be held
+func (p *ConnectionPool) exhaustedLocked(attrs []slog.Attr) {
+	if len(p.idle) == 0 && p.open+p.pending >= p.maxSizeLocked() {
+		p.emit(Event{Type: PoolExhausted, Attrs: attrs})
+	}
+}
+
+// emit sends the event to every subscriber that has room for it
+func (p *ConnectionPool) emit(e Event) {
+	p.subs.mu.Lock()
+	defer p.subs.mu.Unlock()
+	if p.subs.closed {
+		return
+	}
+
+	e.Time = time.Now()
+	e.Pool = p.Config.Name
+	for _, ch := range p.subs.chans {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	if e.Type == PoolClosed {
+		for _, ch := range p.subs.chans {
+			close(ch)
+		}
+		p.subs.chans = nil
+		p.subs.closed = true
+	}
+}
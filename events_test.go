@@ -0,0 +1,84 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func nextEvent(t *testing.T, events <-chan pool.Event) pool.Event {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+	return pool.Event{}
+}
+
+func TestSubscribeReceivesEvents(t *testing.T) {
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Name:          "bridge",
+		Address:       "10.0.0.5:23",
+		Size:          1,
+		RetryDuration: time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				return nil, errors.New("connection refused")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	events := p.Subscribe()
+	<-p.Init()
+
+	e := nextEvent(t, events)
+	require.Equal(t, pool.ConnFailed, e.Type)
+	require.Equal(t, "bridge", e.Pool)
+	require.Equal(t, "10.0.0.5:23", e.Address)
+	require.EqualError(t, e.Err, "connection refused")
+	require.Equal(t, pool.ConnEstablished, nextEvent(t, events).Type)
+
+	c, err := p.TryGet()
+	require.Nil(t, err)
+	_, err = p.TryGet()
+	require.Equal(t, pool.ErrExhausted, err)
+	require.Equal(t, pool.PoolExhausted, nextEvent(t, events).Type)
+
+	require.Nil(t, p.Release(c, errors.New("connection reset")))
+	e = nextEvent(t, events)
+	require.Equal(t, pool.ConnEvicted, e.Type)
+	require.Equal(t, "bad connection", e.Reason)
+	require.Equal(t, pool.ConnEstablished, nextEvent(t, events).Type)
+
+	<-p.Close()
+	require.Equal(t, pool.PoolClosed, nextEvent(t, events).Type)
+	_, ok := <-events
+	require.False(t, ok)
+
+	_, ok = <-p.Subscribe()
+	require.False(t, ok)
+}
+
+func TestUnsubscribeClosesTheChannel(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	events := p.Subscribe()
+	p.Unsubscribe(events)
+	_, ok := <-events
+	require.False(t, ok)
+	require.Equal(t, "ConnEvicted", pool.ConnEvicted.String())
+	<-p.Close()
+}
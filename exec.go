@@ -0,0 +1,95 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// DefaultExecAttempts is the number of times Exec runs the callback if Config.ExecAttempts
+// is not set
+const DefaultExecAttempts = 3
+
+// Exec gets a connection from the pool, calls fn with it and then releases the connection.
+// If fn returns an I/O error, for example the device reset the connection, the connection is
+// thrown away and fn is called again with a different connection, up to Config.ExecAttempts
+// times or until the context is done. Fatal errors, see Config.IsFatal, are returned straight
+// away. Any other error is returned as is and the connection is returned to the pool. Errors
+// are classified in the same way as ReleaseWithError. fn must not close the connection or
+// keep hold of it after returning
+func (p *ConnectionPool) Exec(ctx context.Context, fn func(net.Conn) error) error {
+	attempts := p.Config.ExecAttempts
+	if attempts <= 0 {
+		attempts = DefaultExecAttempts
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		var conn *Connection
+		conn, err = p.GetContext(ctx, GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		err = fn(conn)
+		if err == nil || (!p.isBadConn(err) && !p.isFatal(err)) {
+			p.Release(conn, nil)
+			return err
+		}
+		p.Release(conn, err)
+		if p.isFatal(err) {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
+
+// ReleaseWithError returns the connection to the pool like Release, but instead of throwing
+// the connection away for any error, err is passed to Config.IsBadConn and Config.IsFatal to
+// decide whether the connection is broken or whether it can be reused. err can be nil
+func (p *ConnectionPool) ReleaseWithError(c *Connection, err error) error {
+	if err != nil && !p.isBadConn(err) && !p.isFatal(err) {
+		err = nil
+	}
+	return p.Release(c, err)
+}
+
+func (p *ConnectionPool) isBadConn(err error) bool {
+	if p.Config.IsBadConn != nil {
+		return p.Config.IsBadConn(err)
+	}
+	return IsConnError(err)
+}
+
+func (p *ConnectionPool) isFatal(err error) bool {
+	return p.Config.IsFatal != nil && p.Config.IsFatal(err)
+}
+
+// IsConnError returns true if the error indicates the connection itself is broken, as
+// opposed to an error in the protocol being spoken over it. It is the default used when
+// Config.IsBadConn is not set. Context errors are not connection errors, even though
+// context.DeadlineExceeded satisfies net.Error
+func IsConnError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, net.ErrClosed),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.ECONNABORTED),
+		errors.Is(err, syscall.EPIPE):
+		return true
+	}
+	return false
+}
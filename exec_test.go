@@ -0,0 +1,262 @@
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecRetriesOnIOErrors(t *testing.T) {
+	var newCount int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	calls := 0
+	var conns []net.Conn
+	err = p.Exec(context.Background(), func(c net.Conn) error {
+		calls++
+		conns = append(conns, c)
+		if calls == 1 {
+			return io.EOF
+		}
+		return nil
+	})
+	require.Nil(t, err)
+	require.Equal(t, 2, calls)
+	require.False(t, conns[0].(*pool.Connection).Conn == conns[1].(*pool.Connection).Conn)
+	require.Equal(t, int32(2), atomic.LoadInt32(&newCount))
+	require.Equal(t, 1, p.Stats().Idle)
+}
+
+func TestExecReturnsOtherErrorsWithoutRetrying(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size:         1,
+		ExecAttempts: 2,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	protocolErr := errors.New("unknown command")
+	calls := 0
+	err = p.Exec(context.Background(), func(c net.Conn) error {
+		calls++
+		return protocolErr
+	})
+	require.Equal(t, protocolErr, err)
+	require.Equal(t, 1, calls)
+
+	// Giving up after ExecAttempts I/O errors
+	calls = 0
+	err = p.Exec(context.Background(), func(c net.Conn) error {
+		calls++
+		return io.ErrUnexpectedEOF
+	})
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+	require.Equal(t, 2, calls)
+}
+
+func TestReleaseWithErrorUsesTheClassifier(t *testing.T) {
+	var newCount int32
+	errBusy := errors.New("device busy")
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		IsBadConn: func(err error) bool {
+			return err != errBusy
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.TryGet()
+	require.Nil(t, err)
+	p.ReleaseWithError(c1, errBusy)
+
+	c2, err := p.TryGet()
+	require.Nil(t, err)
+	require.True(t, c1.Conn == c2.Conn)
+
+	p.ReleaseWithError(c2, io.EOF)
+	require.Equal(t, 0, p.Stats().Idle)
+	require.Nil(t, p.WaitReady(context.Background(), 1))
+	require.Equal(t, int32(2), atomic.LoadInt32(&newCount))
+}
+
+func TestIsConnError(t *testing.T) {
+	require.True(t, pool.IsConnError(io.EOF))
+	require.True(t, pool.IsConnError(&net.OpError{Op: "read", Err: errors.New("reset")}))
+	require.False(t, pool.IsConnError(errors.New("unknown command")))
+	require.False(t, pool.IsConnError(context.DeadlineExceeded))
+	require.False(t, pool.IsConnError(context.Canceled))
+}
+
+func TestReleaseWithContextErrorKeepsTheConnection(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.TryGet()
+	require.Nil(t, err)
+	require.Nil(t, p.ReleaseWithError(c1, context.DeadlineExceeded))
+
+	c2, err := p.TryGet()
+	require.Nil(t, err)
+	require.True(t, c1.Conn == c2.Conn)
+}
+
+func TestExecKeepsConnectionOnContextErrors(t *testing.T) {
+	var newCount int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	err = p.Exec(ctx, func(c net.Conn) error {
+		calls++
+		return ctx.Err()
+	})
+	require.Equal(t, context.Canceled, err)
+	require.Equal(t, 1, calls)
+	require.Equal(t, 1, p.Stats().Idle)
+	require.Equal(t, int32(1), atomic.LoadInt32(&newCount))
+}
+
+func TestExecStopsRetryingOnceTheContextIsDone(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size:         1,
+		ExecAttempts: 5,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	err = p.Exec(ctx, func(c net.Conn) error {
+		calls++
+		cancel()
+		return io.EOF
+	})
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, 1, calls)
+}
+
+func TestMarkBadOnIOErrorMarksBrokenConnections(t *testing.T) {
+	for _, mark := range []bool{false, true} {
+		p, err := pool.NewPool(pool.Config{
+			Size:             1,
+			MarkBadOnIOError: mark,
+			NewConnection: func(cfg pool.Config) (net.Conn, error) {
+				return &resetConn{}, nil
+			},
+		})
+		require.Nil(t, err)
+
+		done := p.Init()
+		<-done
+
+		c, err := p.Get(time.Second, pool.GetOptions{})
+		require.Nil(t, err)
+		_, err = c.Read(make([]byte, 1))
+		require.True(t, errors.Is(err, syscall.ECONNRESET))
+		require.Equal(t, !mark, c.IsHealthy())
+		require.Nil(t, p.Release(c, nil))
+		<-p.Close()
+	}
+}
+
+// resetConn is a mockConn that fails every read as if the device reset the connection
+type resetConn struct {
+	mockConn
+}
+
+func (c *resetConn) Read(b []byte) (int, error) {
+	return 0, &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+}
+
+func TestFatalErrorsAreNotRetried(t *testing.T) {
+	var newCount int32
+	errRejected := errors.New("credentials rejected")
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		IsFatal: func(err error) bool {
+			return err == errRejected
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	// Exec doesn't try another connection and the connection isn't reused, even though
+	// IsBadConn doesn't treat the error as a connection error
+	calls := 0
+	err = p.Exec(context.Background(), func(c net.Conn) error {
+		calls++
+		return errRejected
+	})
+	require.Equal(t, errRejected, err)
+	require.Equal(t, 1, calls)
+	require.Nil(t, p.WaitReady(context.Background(), 1))
+	require.Equal(t, int32(2), atomic.LoadInt32(&newCount))
+
+	c, err := p.TryGet()
+	require.Nil(t, err)
+	require.Nil(t, p.ReleaseWithError(c, errRejected))
+	require.Nil(t, p.WaitReady(context.Background(), 1))
+	require.Equal(t, int32(3), atomic.LoadInt32(&newCount))
+	<-p.Close()
+}
@@ -0,0 +1,45 @@
+package pool
+
+import (
+	"encoding/json"
+	"expvar"
+)
+
+// ExpvarName is the expvar variable the stats of pools with Config.Expvar set are published
+// under, keyed by their Config.Name
+const ExpvarName = "connection_pool"
+
+var expvarPools = expvar.NewMap(ExpvarName)
+
+// expvarStats publishes a pool's Stats as an expvar.Var
+type expvarStats struct {
+	p *ConnectionPool
+}
+
+func (v expvarStats) String() string {
+	b, err := json.Marshal(v.p.Stats())
+	if err != nil {
+		return "null"
+	}
+	return string(b)
+}
+
+// publish adds the pool's stats to the expvar map if Config.Expvar is set, replacing any
+// pool already published with the same name
+func (p *ConnectionPool) publish() {
+	if p.Config.Expvar {
+		expvarPools.Set(p.Config.Name, expvarStats{p: p})
+	}
+}
+
+// unpublish removes the pool's stats from the expvar map, as long as they haven't been
+// replaced by another pool with the same name. It must not be called with p.mu held since
+// expvar holds its own lock while reading the stats
+func (p *ConnectionPool) unpublish() {
+	if !p.Config.Expvar {
+		return
+	}
+	if v, ok := expvarPools.Get(p.Config.Name).(expvarStats); ok && v.p == p {
+		expvarPools.Delete(p.Config.Name)
+	}
+}
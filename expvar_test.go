@@ -0,0 +1,36 @@
+package pool_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"net"
+	"testing"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpvarPublishesStats(t *testing.T) {
+	p, err := pool.New("10.0.0.5:23",
+		pool.WithName("thermostat"),
+		pool.WithSize(2),
+		pool.WithExpvar(),
+		pool.WithDialFunc(func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		}),
+	)
+	require.Nil(t, err)
+	<-p.Init()
+
+	pools := expvar.Get(pool.ExpvarName).(*expvar.Map)
+	v := pools.Get("thermostat")
+	require.NotNil(t, v)
+
+	var s pool.Stats
+	require.Nil(t, json.Unmarshal([]byte(v.String()), &s))
+	require.Equal(t, 2, s.Size)
+	require.Equal(t, 2, s.Idle)
+
+	<-p.Close()
+	require.Nil(t, pools.Get("thermostat"))
+}
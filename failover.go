@@ -0,0 +1,152 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Failover moves the pool to a backup address once Failures connection attempts in a row to
+// the address it is using have failed. The backups in Addresses are tried in order after
+// the primary address, Config.Address or the one given to SetAddress, going back to the start
+// of the list after the last one. While the pool is using a backup it tries to connect to the
+// primary address every ProbeInterval and moves back as soon as that works. Each time the
+// pool moves, connections to the old address are replaced in the same way as SetAddress.
+// OnFailover if set is called from a new goroutine every time the pool moves to a different
+// address
+type Failover struct {
+	Addresses     []string
+	Failures      int
+	ProbeInterval time.Duration
+
+	OnFailover func(from, to string)
+}
+
+func (f Failover) validate() error {
+	switch {
+	case len(f.Addresses) == 0:
+		return errors.New("Addresses must not be empty")
+	case f.Failures <= 0:
+		return errors.New("Failures must be greater than 0")
+	case f.ProbeInterval <= 0:
+		return errors.New("ProbeInterval must be greater than 0")
+	}
+	for _, addr := range f.Addresses {
+		if addr == "" {
+			return errors.New("Addresses must not contain an empty address")
+		}
+	}
+	return nil
+}
+
+// ActiveAddress returns the address new connections are made to, this is Config.Address, or
+// the address given to SetAddress, unless the pool has failed over to one of the
+// Config.Failover addresses
+func (p *ConnectionPool) ActiveAddress() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.targetLocked()
+}
+
+// targetLocked returns the address new connections are made to, p.mu must be held
+func (p *ConnectionPool) targetLocked() string {
+	if p.failoverIndex == 0 {
+		return p.address
+	}
+	return p.Config.Failover.Addresses[p.failoverIndex-1]
+}
+
+// dialResultLocked counts a connection attempt to addr towards Config.Failover, moving the
+// pool to the next address once there have been too many failures in a row or straight away
+// if the error is fatal. It returns true if the pool moved, p.mu must be held
+func (p *ConnectionPool) dialResultLocked(addr string, err error) bool {
+	f := p.Config.Failover
+	if f == nil || addr != p.targetLocked() {
+		return false
+	}
+	if err == nil {
+		p.failoverFailures = 0
+		return false
+	}
+
+	p.failoverFailures++
+	if p.failoverFailures < f.Failures && !p.isFatal(err) {
+		return false
+	}
+	return p.failOverLocked(addr)
+}
+
+// failOverLocked moves the pool from addr to the next Config.Failover address, it returns
+// false if the pool isn't using addr. The caller must call migrate once it has released p.mu,
+// p.mu must be held
+func (p *ConnectionPool) failOverLocked(addr string) bool {
+	f := p.Config.Failover
+	if f == nil || addr != p.targetLocked() {
+		return false
+	}
+	p.moveLocked((p.failoverIndex + 1) % (len(f.Addresses) + 1))
+	if p.failoverIndex != 0 && !p.probing {
+		p.probing = true
+		stop := p.stop
+		p.goLabeled("failover-probe", func() { p.probePrimary(stop) })
+	}
+	return true
+}
+
+// moveLocked makes the address at index in the failover list the one new connections are
+// made to, the caller must call migrate once it has released p.mu
+func (p *ConnectionPool) moveLocked(index int) {
+	from := p.targetLocked()
+	p.failoverIndex = index
+	p.failoverFailures = 0
+	p.resolved = nil
+	if fn := p.Config.Failover.OnFailover; fn != nil {
+		to := p.targetLocked()
+		go p.safely("OnFailover", func() { fn(from, to) })
+	}
+}
+
+// probePrimary tries to connect to the primary address every Config.Failover.ProbeInterval while
+// the pool is using a backup address, moving the pool back once it succeeds. It returns once
+// the pool is back on the primary address or stop is closed
+func (p *ConnectionPool) probePrimary(stop chan struct{}) {
+	ticker := time.NewTicker(p.Config.Failover.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		p.mu.Lock()
+		primary, back := p.address, p.failoverIndex == 0
+		if back {
+			p.probing = false
+		}
+		p.mu.Unlock()
+		if back {
+			return
+		}
+
+		if !p.waitDialTurn(context.Background(), stop) {
+			return
+		}
+		c, _, err := p.dialTo(primary)
+		p.dialFinished()
+		if err != nil {
+			continue
+		}
+		c.Close()
+
+		p.mu.Lock()
+		if p.failoverIndex != 0 && p.address == primary {
+			p.moveLocked(0)
+		}
+		p.probing = false
+		p.mu.Unlock()
+		p.migrate()
+		return
+	}
+}
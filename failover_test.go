@@ -0,0 +1,69 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailoverMovesToBackupAndBack(t *testing.T) {
+	var mu sync.Mutex
+	primaryUp := true
+	moves := make(chan string, 10)
+	p, err := pool.NewPool(pool.Config{
+		Size:          2,
+		Address:       "10.0.0.5:23",
+		RetryDuration: time.Millisecond,
+		Failover: &pool.Failover{
+			Addresses:     []string{"10.0.0.6:23"},
+			Failures:      2,
+			ProbeInterval: 10 * time.Millisecond,
+			OnFailover: func(from, to string) {
+				moves <- from + "->" + to
+			},
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if cfg.Address == "10.0.0.5:23" && !primaryUp {
+				return nil, errors.New("connection refused")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	require.Equal(t, "10.0.0.5:23", p.ActiveAddress())
+
+	mu.Lock()
+	primaryUp = false
+	mu.Unlock()
+	p.InvalidateAll()
+
+	require.Equal(t, "10.0.0.5:23->10.0.0.6:23", <-moves)
+	require.Equal(t, "10.0.0.6:23", p.ActiveAddress())
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, nil))
+
+	mu.Lock()
+	primaryUp = true
+	mu.Unlock()
+	require.Equal(t, "10.0.0.6:23->10.0.0.5:23", <-moves)
+	require.Equal(t, "10.0.0.5:23", p.ActiveAddress())
+	<-p.Close()
+}
+
+func TestFailoverValidation(t *testing.T) {
+	_, err := pool.NewPool(pool.Config{
+		Size:     1,
+		Address:  "10.0.0.5:23",
+		Failover: &pool.Failover{Failures: 1, ProbeInterval: time.Second},
+	})
+	require.True(t, errors.Is(err, pool.ErrInvalidConfig))
+}
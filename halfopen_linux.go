@@ -0,0 +1,21 @@
+//go:build linux && !386
+
+package pool
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// tcpCloseWait is the TCP state of a socket whose peer has closed its side of the connection
+const tcpCloseWait = 8
+
+// peerClosed reports whether the other end has closed the socket, even if there is data left
+// to read, such as the session tickets a TLS 1.3 server sends after the handshake
+func peerClosed(fd uintptr) bool {
+	var info syscall.TCPInfo
+	size := uint32(unsafe.Sizeof(info))
+	_, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, fd, syscall.IPPROTO_TCP, syscall.TCP_INFO,
+		uintptr(unsafe.Pointer(&info)), uintptr(unsafe.Pointer(&size)), 0)
+	return errno == 0 && info.State == tcpCloseWait
+}
@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package pool
+
+import "net"
+
+// checkHalfOpen can't peek at sockets on this platform so every connection is assumed to
+// be fine
+func checkHalfOpen(c net.Conn) error {
+	return nil
+}
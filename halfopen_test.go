@@ -0,0 +1,101 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package pool_test
+
+import (
+	"crypto/tls"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckHalfOpenReplacesConnectionsClosedByThePeer(t *testing.T) {
+	testCheckHalfOpen(t, pool.Config{})
+}
+
+func TestCheckHalfOpenLooksThroughDeadlines(t *testing.T) {
+	testCheckHalfOpen(t, pool.Config{ReadTimeout: time.Second, WriteTimeout: time.Second})
+}
+
+func TestCheckHalfOpenLooksThroughRateLimits(t *testing.T) {
+	testCheckHalfOpen(t, pool.Config{WriteRateLimit: 10})
+}
+
+func TestCheckHalfOpenWorksForTLS(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH == "386" {
+		t.Skip("the session tickets a TLS 1.3 server sends hide the close from a peek")
+	}
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCertificate(t, "bridge", certFile, keyFile)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.Nil(t, err)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	require.Nil(t, err)
+	testCheckHalfOpenOn(t, l, pool.Config{
+		Address: "tls://" + l.Addr().String(),
+		TLS:     &pool.TLSConfig{InsecureSkipVerify: true},
+	})
+}
+
+// testCheckHalfOpen checks that a pool made with cfg replaces a connection the device has
+// closed, and keeps one that is still open
+func testCheckHalfOpen(t *testing.T, cfg pool.Config) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	cfg.Address = l.Addr().String()
+	testCheckHalfOpenOn(t, l, cfg)
+}
+
+// testCheckHalfOpenOn is testCheckHalfOpen for devices listening on l
+func testCheckHalfOpenOn(t *testing.T, l net.Listener, cfg pool.Config) {
+	defer l.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if tc, ok := c.(*tls.Conn); ok {
+				tc.Handshake()
+			}
+			accepted <- c
+		}
+	}()
+
+	cfg.Size = 1
+	cfg.CheckHalfOpen = true
+	p, err := pool.NewPool(cfg)
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	// The device goes away without the client sending anything
+	server := <-accepted
+	require.Nil(t, server.Close())
+	time.Sleep(10 * time.Millisecond)
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	select {
+	case <-accepted:
+	case <-time.After(time.Second):
+		t.Fatal("the closed connection wasn't replaced")
+	}
+
+	// An open connection with nothing to read passes the check
+	require.Nil(t, p.Release(c, nil))
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.True(t, c.Conn == c2.Conn)
+	require.Nil(t, p.Release(c2, nil))
+	<-p.Close()
+}
@@ -0,0 +1,51 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package pool
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+// checkHalfOpen peeks at the socket behind the connection without blocking. It returns
+// io.EOF if the other end has closed the connection, or the error from the socket if it has
+// failed. Wrappers with a NetConn method, such as DeadlineConn and *tls.Conn, are looked
+// through to the TCP connection, only the socket is peeked at and nothing is read through
+// them. Connections that don't wrap a socket are assumed to be fine
+func checkHalfOpen(c net.Conn) error {
+	if tc := tcpConn(c); tc != nil {
+		c = tc
+	}
+	sc, ok := c.(syscall.Conn)
+	if !ok {
+		return nil
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil
+	}
+
+	var checkErr error
+	err = raw.Read(func(fd uintptr) bool {
+		if peerClosed(fd) {
+			checkErr = io.EOF
+			return true
+		}
+		var buf [1]byte
+		n, _, err := syscall.Recvfrom(int(fd), buf[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+		switch {
+		case n == 0 && err == nil:
+			checkErr = io.EOF
+		case err != nil && !errors.Is(err, syscall.EAGAIN) && !errors.Is(err, syscall.EWOULDBLOCK):
+			checkErr = err
+		}
+		// Returning true stops Read from waiting for the socket to be readable
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return checkErr
+}
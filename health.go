@@ -0,0 +1,227 @@
+package pool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// healthCheck pings every idle connection each Config.HealthCheckInterval until stop is
+// closed, replacing the ones that fail
+func (p *ConnectionPool) healthCheck(stop chan struct{}) {
+	p.everyIdle(p.Config.HealthCheckInterval, "Ping", p.Config.Ping, stop)
+}
+
+// heartbeat writes Config.HeartbeatPayload to every idle connection, or calls Config.Heartbeat
+// with it, each Config.HeartbeatInterval until stop is closed, replacing the ones that fail
+func (p *ConnectionPool) heartbeat(stop chan struct{}) {
+	beat := p.Config.Heartbeat
+	if beat == nil {
+		payload := p.Config.HeartbeatPayload
+		beat = func(c net.Conn) error {
+			_, err := c.Write(payload)
+			return err
+		}
+	}
+	p.everyIdle(p.Config.HeartbeatInterval, "Heartbeat", beat, stop)
+}
+
+// everyIdle runs the named check on every idle connection each interval until stop is closed,
+// replacing the connections it fails for
+func (p *ConnectionPool) everyIdle(interval time.Duration, name string, fn func(net.Conn) error, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		p.mu.Lock()
+		idle := append([]*pooledConn(nil), p.idle...)
+		p.mu.Unlock()
+
+		for _, pc := range idle {
+			if isDone(stop) {
+				return
+			}
+			p.checkIdle(pc, name, fn)
+		}
+	}
+}
+
+// checkIdle takes the connection out of the idle list, as long as it is still there, and calls
+// the named check with it. Connections that pass are put back, ones that fail are marked as
+// bad and replaced
+func (p *ConnectionPool) checkIdle(pc *pooledConn, name string, fn func(net.Conn) error) {
+	if !p.takeIdle(pc) {
+		return
+	}
+
+	if err := check(name, fn, pc.Conn); err != nil {
+		if pc.setBad() {
+			p.mu.Lock()
+			p.countBadLocked(pc)
+			p.mu.Unlock()
+		}
+		p.reportEvict(pc, evictUnhealthy, err)
+		p.discard(pc, evictUnhealthy)
+		p.refill()
+		return
+	}
+
+	p.mu.Lock()
+	p.putLocked(pc)
+	p.mu.Unlock()
+}
+
+// takeIdle removes the connection from the idle list, returning false if it wasn't idle
+func (p *ConnectionPool) takeIdle(pc *pooledConn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, ipc := range p.idle {
+		if ipc == pc {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// HealthState summarises how well the pool is connected to its device
+type HealthState int
+
+const (
+	// Healthy means the pool has all of its connections
+	Healthy HealthState = iota
+
+	// Degraded means some of the connections are missing or have been marked as bad
+	Degraded
+
+	// Down means the pool has no working connections
+	Down
+)
+
+func (s HealthState) String() string {
+	switch s {
+	case Healthy:
+		return "healthy"
+	case Degraded:
+		return "degraded"
+	case Down:
+		return "down"
+	}
+	return fmt.Sprintf("HealthState(%d)", int(s))
+}
+
+// MarshalText marshals the state as its name, so it appears as a string in JSON
+func (s HealthState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText is the inverse of MarshalText
+func (s *HealthState) UnmarshalText(text []byte) error {
+	for _, state := range []HealthState{Healthy, Degraded, Down} {
+		if string(text) == state.String() {
+			*s = state
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown health state %q", text)
+}
+
+// HealthStatus is the health of the pool reported by Health
+type HealthStatus struct {
+	State HealthState
+
+	// Live is the number of open connections that haven't been marked as bad, Failed is the
+	// number the pool is short of its size, because they are bad or couldn't be created
+	Live   int
+	Failed int
+
+	// LastDialErr is the error from the most recent failed connection attempt, nil if there
+	// hasn't been one. It is kept after later attempts succeed
+	LastDialErr error
+}
+
+// healthJSON is how HealthStatus is marshaled to JSON, with the error as its message
+type healthJSON struct {
+	State       HealthState `json:"state"`
+	Live        int         `json:"live"`
+	Failed      int         `json:"failed"`
+	LastDialErr string      `json:"last_dial_error,omitempty"`
+}
+
+// MarshalJSON marshals the status with LastDialErr as the error's message, which is left out
+// if there hasn't been an error
+func (h HealthStatus) MarshalJSON() ([]byte, error) {
+	v := healthJSON{State: h.State, Live: h.Live, Failed: h.Failed}
+	if h.LastDialErr != nil {
+		v.LastDialErr = h.LastDialErr.Error()
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON is the inverse of MarshalJSON, LastDialErr is set to an error with the
+// original error's message
+func (h *HealthStatus) UnmarshalJSON(b []byte) error {
+	var v healthJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*h = HealthStatus{State: v.State, Live: v.Live, Failed: v.Failed}
+	if v.LastDialErr != "" {
+		h.LastDialErr = errors.New(v.LastDialErr)
+	}
+	return nil
+}
+
+// Health reports whether the pool is connected to its device, for dashboards and readiness
+// checks. Lazy pools only count as degraded or down once they have been used, as they don't
+// open connections until they are needed
+func (p *ConnectionPool) Health() HealthStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	h := HealthStatus{Live: p.healthyLocked(), LastDialErr: p.lastDialErr}
+	want := p.size
+	if p.Config.Lazy {
+		want = p.open
+	}
+	if h.Live < want {
+		h.Failed = want - h.Live
+	}
+
+	switch {
+	case h.Live == 0 && want > 0:
+		h.State = Down
+	case h.Failed > 0:
+		h.State = Degraded
+	}
+	return h
+}
+
+// Ready reports whether the pool can hand out connections, for readiness probes. A pool is
+// ready once it has at least one working connection, lazy pools before they are first used,
+// as long as it isn't closed, draining, quiesced or paused
+func (p *ConnectionPool) Ready() bool {
+	p.mu.Lock()
+	available := p.unavailableLocked() == nil && !p.paused
+	p.mu.Unlock()
+	return available && p.Health().State != Down
+}
+
+// AllReady reports whether every one of the pools is Ready
+func AllReady(pools ...*ConnectionPool) bool {
+	for _, p := range pools {
+		if !p.Ready() {
+			return false
+		}
+	}
+	return true
+}
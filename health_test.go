@@ -0,0 +1,156 @@
+package pool_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthCheckReplacesIdleConnectionsThatFailPing(t *testing.T) {
+	var dials, pings int32
+	first := &mockConn{}
+	p, err := pool.NewPool(pool.Config{
+		Size:                1,
+		HealthCheckInterval: 5 * time.Millisecond,
+		Ping: func(c net.Conn) error {
+			atomic.AddInt32(&pings, 1)
+			if c == net.Conn(first) {
+				return errors.New("no response")
+			}
+			return nil
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				return first, nil
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&dials) == 2 && atomic.LoadInt32(&pings) >= 3
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int32(2), atomic.LoadInt32(&dials))
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.False(t, c.Conn == net.Conn(first))
+	require.Nil(t, p.Release(c, nil))
+	<-p.Close()
+}
+
+func TestHealthCheckNeedsPing(t *testing.T) {
+	_, err := pool.NewPool(pool.Config{
+		Size:                1,
+		HealthCheckInterval: time.Second,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.True(t, errors.Is(err, pool.ErrInvalidConfig))
+}
+
+func TestHeartbeatIsWrittenToIdleConnections(t *testing.T) {
+	conn := &recordConn{}
+	p, err := pool.NewPool(pool.Config{
+		Size:              1,
+		HeartbeatInterval: 5 * time.Millisecond,
+		HeartbeatPayload:  []byte("PING\n"),
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return conn, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	require.Eventually(t, func() bool {
+		return bytes.HasPrefix(conn.written(), []byte("PING\nPING\n"))
+	}, time.Second, time.Millisecond)
+	<-p.Close()
+}
+
+// recordConn is a mockConn that records everything written to it
+type recordConn struct {
+	mockConn
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (c *recordConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(b)
+}
+
+func (c *recordConn) written() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte(nil), c.buf.Bytes()...)
+}
+
+func TestHealthReflectsLiveConnections(t *testing.T) {
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Size:            2,
+		MaxDialAttempts: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) > 2 {
+				return nil, errors.New("connection refused")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	require.Equal(t, pool.Down, p.Health().State)
+
+	done := p.Init()
+	<-done
+	require.Equal(t, pool.HealthStatus{State: pool.Healthy, Live: 2}, p.Health())
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, errors.New("reset")))
+	require.Eventually(t, func() bool {
+		return p.Health().LastDialErr != nil
+	}, time.Second, time.Millisecond)
+
+	h := p.Health()
+	require.Equal(t, pool.Degraded, h.State)
+	require.Equal(t, 1, h.Live)
+	require.Equal(t, 1, h.Failed)
+	require.EqualError(t, h.LastDialErr, "connection refused")
+	require.Equal(t, "degraded", h.State.String())
+	<-p.Close()
+}
+
+func TestHealthStatusJSON(t *testing.T) {
+	h := pool.HealthStatus{State: pool.Degraded, Live: 1, Failed: 1, LastDialErr: errors.New("connection refused")}
+	b, err := json.Marshal(h)
+	require.Nil(t, err)
+	require.JSONEq(t, `{"state":"degraded","live":1,"failed":1,"last_dial_error":"connection refused"}`, string(b))
+
+	var got pool.HealthStatus
+	require.Nil(t, json.Unmarshal(b, &got))
+	require.Equal(t, pool.Degraded, got.State)
+	require.EqualError(t, got.LastDialErr, "connection refused")
+
+	b, err = json.Marshal(pool.HealthStatus{})
+	require.Nil(t, err)
+	require.JSONEq(t, `{"state":"healthy","live":0,"failed":0}`, string(b))
+	require.NotNil(t, json.Unmarshal([]byte(`{"state":"sleepy"}`), &got))
+}
@@ -0,0 +1,106 @@
+package pool
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// DefaultLatencyBuckets are the histogram buckets used when Config.LatencyBuckets isn't set
+var DefaultLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// DefaultLifetimeBuckets are the histogram buckets used when Config.LifetimeBuckets isn't set
+var DefaultLifetimeBuckets = []time.Duration{
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+}
+
+// Histogram counts how many times took up to each of Buckets. Counts[i] is the number of
+// times that took longer than Buckets[i-1] and no longer than Buckets[i], the extra last
+// count is the number of times that took longer than every bucket
+type Histogram struct {
+	Buckets []time.Duration `json:"buckets_ns"`
+	Counts  []int64         `json:"counts"`
+
+	// Count is the number of times recorded and Sum is their total
+	Count int64         `json:"count"`
+	Sum   time.Duration `json:"sum_ns"`
+}
+
+func newHistogram(buckets []time.Duration) *Histogram {
+	if buckets == nil {
+		buckets = DefaultLatencyBuckets
+	}
+	return &Histogram{Buckets: buckets, Counts: make([]int64, len(buckets)+1)}
+}
+
+// observe records a time
+func (h *Histogram) observe(d time.Duration) {
+	i := sort.Search(len(h.Buckets), func(i int) bool { return d <= h.Buckets[i] })
+	h.Counts[i]++
+	h.Count++
+	h.Sum += d
+}
+
+// snapshot returns a copy of the histogram that isn't changed by later calls to observe
+func (h *Histogram) snapshot() Histogram {
+	s := *h
+	s.Counts = append([]int64(nil), h.Counts...)
+	return s
+}
+
+// Quantile returns the upper bound of the bucket holding the q quantile, q is between 0 and 1,
+// for example 0.99 for the 99th percentile. If the quantile is beyond the last bucket the
+// last bucket is returned, zero is returned if nothing has been recorded
+func (h Histogram) Quantile(q float64) time.Duration {
+	if h.Count == 0 || len(h.Buckets) == 0 {
+		return 0
+	}
+
+	rank := int64(q * float64(h.Count))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen int64
+	for i, n := range h.Counts[:len(h.Buckets)] {
+		seen += n
+		if seen >= rank {
+			return h.Buckets[i]
+		}
+	}
+	return h.Buckets[len(h.Buckets)-1]
+}
+
+func validateBuckets(buckets []time.Duration) error {
+	if buckets != nil && len(buckets) == 0 {
+		return errors.New("must not be empty")
+	}
+	for i, b := range buckets {
+		if b <= 0 {
+			return errors.New("buckets must be greater than 0")
+		}
+		if i > 0 && b <= buckets[i-1] {
+			return errors.New("buckets must be in increasing order")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	h := pool.Histogram{
+		Buckets: []time.Duration{time.Millisecond, 10 * time.Millisecond, 100 * time.Millisecond},
+		Counts:  []int64{90, 8, 1, 1},
+		Count:   100,
+	}
+	require.Equal(t, time.Millisecond, h.Quantile(0.5))
+	require.Equal(t, 10*time.Millisecond, h.Quantile(0.95))
+	require.Equal(t, 100*time.Millisecond, h.Quantile(0.99))
+	require.Equal(t, 100*time.Millisecond, h.Quantile(1))
+	require.Zero(t, pool.Histogram{}.Quantile(0.5))
+}
+
+func TestStatsRecordsWaitAndDialTimes(t *testing.T) {
+	buckets := []time.Duration{5 * time.Millisecond, time.Hour}
+	p, err := pool.NewPool(pool.Config{
+		Size:           2,
+		LatencyBuckets: buckets,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			time.Sleep(10 * time.Millisecond)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, nil))
+
+	s := p.Stats()
+	require.Equal(t, buckets, s.DialTimes.Buckets)
+	require.Equal(t, []int64{0, 2, 0}, s.DialTimes.Counts)
+	require.Equal(t, int64(2), s.DialTimes.Count)
+	require.True(t, s.DialTimes.Sum >= 20*time.Millisecond)
+	require.Equal(t, []int64{1, 0, 0}, s.WaitTimes.Counts)
+
+	// The snapshot isn't changed by later calls
+	c, err = p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, nil))
+	require.Equal(t, int64(1), s.WaitTimes.Count)
+	require.Equal(t, int64(2), p.Stats().WaitTimes.Count)
+	<-p.Close()
+}
+
+func TestLatencyBucketsMustIncrease(t *testing.T) {
+	_, err := pool.NewPool(pool.Config{
+		Size:           1,
+		Address:        "10.0.0.5:23",
+		LatencyBuckets: []time.Duration{time.Second, time.Millisecond},
+	})
+	require.True(t, errors.Is(err, pool.ErrInvalidConfig))
+}
+
+func TestStatsRecordsConnectionLifetimes(t *testing.T) {
+	buckets := []time.Duration{10 * time.Millisecond, time.Hour}
+	p, err := pool.NewPool(pool.Config{
+		Size:            1,
+		LifetimeBuckets: buckets,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	require.Equal(t, int64(0), p.Stats().Lifetimes.Count)
+
+	time.Sleep(20 * time.Millisecond)
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, errors.New("connection reset")))
+
+	s := p.Stats()
+	require.Equal(t, buckets, s.Lifetimes.Buckets)
+	require.Equal(t, []int64{0, 1, 0}, s.Lifetimes.Counts)
+	require.True(t, s.Lifetimes.Sum >= 20*time.Millisecond)
+
+	// Closing the pool doesn't count
+	<-p.Init()
+	<-p.Close()
+	require.Equal(t, int64(1), p.Stats().Lifetimes.Count)
+}
+
+func TestLifetimeBucketsMustIncrease(t *testing.T) {
+	_, err := pool.NewPool(pool.Config{
+		Size:            1,
+		Address:         "10.0.0.5:23",
+		LifetimeBuckets: []time.Duration{time.Hour, time.Minute},
+	})
+	require.True(t, errors.Is(err, pool.ErrInvalidConfig))
+}
@@ -0,0 +1,14 @@
+package pool
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// goLabeled runs fn in a new goroutine with pprof labels giving the pool's Name and the task
+// the goroutine does, so goroutine profiles from a program with many pools show which pool
+// each of its goroutines belongs to. Goroutines started by fn inherit the labels
+func (p *ConnectionPool) goLabeled(task string, fn func()) {
+	labels := pprof.Labels("pool", p.Config.Name, "task", task)
+	go pprof.Do(context.Background(), labels, func(context.Context) { fn() })
+}
@@ -0,0 +1,81 @@
+package pool_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackgroundGoroutinesHavePprofLabels(t *testing.T) {
+	dialing := make(chan struct{})
+	unblock := make(chan struct{})
+	p, err := pool.NewPool(pool.Config{
+		Name: "porch-light",
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			close(dialing)
+			<-unblock
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	done := p.Init()
+	<-dialing
+
+	var buf bytes.Buffer
+	require.Nil(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+	require.Contains(t, buf.String(), `"pool":"porch-light"`)
+	require.Contains(t, buf.String(), `"task":"dial"`)
+
+	close(unblock)
+	<-done
+	<-p.Close()
+}
+
+// labeledGoroutines returns how many goroutines are running the task for the pool
+func labeledGoroutines(t *testing.T, name, task string) int {
+	var buf bytes.Buffer
+	require.Nil(t, pprof.Lookup("goroutine").WriteTo(&buf, 1))
+	count := 0
+	for _, record := range strings.Split(buf.String(), "\n\n") {
+		if strings.Contains(record, fmt.Sprintf(`"pool":%q`, name)) && strings.Contains(record, fmt.Sprintf(`"task":%q`, task)) {
+			var n int
+			fmt.Sscanf(record, "%d @", &n)
+			count += n
+		}
+	}
+	return count
+}
+
+func TestWorkersStopWhenThePoolIsDrained(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Name:        "garage-door",
+		Size:        1,
+		IdleTimeout: time.Minute,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	for i := 0; i < 20; i++ {
+		<-p.Init()
+		require.Nil(t, p.Drain(context.Background()))
+	}
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	// Each worker is given the stop channel of the Init that started it, so none of them
+	// outlive their Drain
+	require.Eventually(t, func() bool {
+		return labeledGoroutines(t, "garage-door", "reaper") == 1
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,80 @@
+package pool
+
+import (
+	"log"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+// Leak describes a connection that has been checked out for longer than Config.LeakTimeout
+type Leak struct {
+	// Pool is the name of the pool the connection belongs to
+	Pool string
+
+	// CheckedOut is when the connection was checked out and Held how long it had been held
+	// for when the leak was reported
+	CheckedOut time.Time
+	Held       time.Duration
+
+	// Stack is the stack trace of the goroutine that checked out the connection
+	Stack []byte
+}
+
+// leakStack returns the stack of the calling goroutine if leak detection is on, nil otherwise
+func (p *ConnectionPool) leakStack() []byte {
+	if p.Config.LeakTimeout <= 0 {
+		return nil
+	}
+	return debug.Stack()
+}
+
+// trackLeases records the calling goroutine as the one that checked out the connections
+func (p *ConnectionPool) trackLeases(conns []*Connection) {
+	stack := p.leakStack()
+	if stack == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range conns {
+		c.stack = stack
+	}
+}
+
+// detectLeaks reports connections that have been checked out for longer than
+// Config.LeakTimeout until stop is closed, each leak is reported once
+func (p *ConnectionPool) detectLeaks(stop chan struct{}) {
+	ticker := time.NewTicker(p.Config.LeakTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		var leaks []Leak
+		now := time.Now()
+		p.mu.Lock()
+		for pc, c := range p.inUse {
+			if held := now.Sub(pc.lastUsed); !c.leaked && held > p.Config.LeakTimeout {
+				c.leaked = true
+				leaks = append(leaks, Leak{Pool: p.Config.Name, CheckedOut: pc.lastUsed, Held: held, Stack: c.stack})
+			}
+		}
+		p.mu.Unlock()
+
+		for _, l := range leaks {
+			if p.Config.OnLeak != nil {
+				p.safely("OnLeak", func() { p.Config.OnLeak(l) })
+			} else if p.log != nil {
+				p.log.Warn("connection checked out without being released", slog.Duration("held", l.Held), slog.Time("checked_out", l.CheckedOut), slog.String("stack", string(l.Stack)))
+			} else {
+				log.Printf("pool %q: connection checked out for %s without being released, checked out at:\n%s", l.Pool, l.Held, l.Stack)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package pool_test
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeakedConnectionsAreReportedWithTheirStack(t *testing.T) {
+	leaks := make(chan pool.Leak, 10)
+	p, err := pool.NewPool(pool.Config{
+		Name:        "hub",
+		Size:        1,
+		LeakTimeout: 10 * time.Millisecond,
+		OnLeak: func(l pool.Leak) {
+			leaks <- l
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+
+	l := <-leaks
+	require.Equal(t, "hub", l.Pool)
+	require.True(t, l.Held > 10*time.Millisecond)
+	require.Contains(t, string(l.Stack), "TestLeakedConnectionsAreReportedWithTheirStack")
+
+	// Each leak is only reported once
+	time.Sleep(30 * time.Millisecond)
+	require.Len(t, leaks, 0)
+	require.Nil(t, p.Release(c, nil))
+	<-p.Close()
+}
+
+func TestConnectionsHeldTooLongAreReclaimed(t *testing.T) {
+	var closes int32
+	p, err := pool.NewPool(pool.Config{
+		Size:             1,
+		MaxLeaseDuration: 10 * time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{CloseCalled: func(*mockConn) {
+				atomic.AddInt32(&closes, 1)
+			}}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+
+	// The hung caller's connection is closed and another caller gets a new one
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.False(t, c1.Conn == c2.Conn)
+	require.Equal(t, int32(1), atomic.LoadInt32(&closes))
+	require.Equal(t, pool.ErrLeaseExpired, p.Release(c1, nil))
+	require.Nil(t, p.Release(c2, nil))
+	<-p.Close()
+}
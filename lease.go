@@ -0,0 +1,45 @@
+package pool
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrLeaseExpired is returned when releasing a connection that the pool has already taken
+// back because it was checked out for longer than Config.MaxLeaseDuration
+var ErrLeaseExpired = errors.New("connection lease expired")
+
+// reclaimLeases closes and replaces connections that have been checked out for longer than
+// Config.MaxLeaseDuration until stop is closed
+func (p *ConnectionPool) reclaimLeases(stop chan struct{}) {
+	ticker := time.NewTicker(p.Config.MaxLeaseDuration / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		var expired []*pooledConn
+		now := time.Now()
+		p.mu.Lock()
+		for pc, c := range p.inUse {
+			if now.Sub(pc.lastUsed) > p.Config.MaxLeaseDuration {
+				c.reclaimed = true
+				delete(p.inUse, pc)
+				expired = append(expired, pc)
+			}
+		}
+		if p.closed && len(p.inUse) == 0 {
+			p.releasedLocked()
+		}
+		p.mu.Unlock()
+
+		for _, pc := range expired {
+			p.discard(pc, closeLeaseExpired)
+			p.refill()
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package pool
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// The reasons logged when a connection is evicted from the pool
+const (
+	evictBad       = "bad connection"
+	evictIdle      = "idle timeout"
+	evictRetired   = "retired"
+	evictSurplus   = "surplus"
+	evictUnhealthy = "failed health check"
+)
+
+// The other reasons passed to Config.OnClose
+const (
+	closeAddressChanged = "address changed"
+	closeDrained        = "drained"
+	closeLeaseExpired   = "lease expired"
+	closeNetworkChanged = "network changed"
+	closePoolClosed     = "pool closed"
+	closeReplaced       = "replaced"
+)
+
+// newLogger returns Config.Logger with the pool's name attached, nil if it isn't set
+func newLogger(c Config) *slog.Logger {
+	if c.Logger == nil {
+		return nil
+	}
+	return c.Logger.With(slog.String("pool", c.Name))
+}
+
+// reportDial logs a connection attempt to addr and sends its event, p.mu must not be held
+func (p *ConnectionPool) reportDial(addr string, took time.Duration, err error) {
+	if err != nil {
+		p.emit(Event{Type: ConnFailed, Address: addr, Err: err})
+	} else {
+		p.emit(Event{Type: ConnEstablished, Address: addr})
+	}
+	if p.log == nil {
+		return
+	}
+	if err != nil {
+		p.log.Warn("connection attempt failed", slog.String("address", addr), slog.Duration("took", took), slog.Any("error", err))
+		return
+	}
+	p.log.Debug("connection established", slog.String("address", addr), slog.Duration("took", took))
+}
+
+// reportEvict records the lifetime of a connection being thrown away for reason, logs it and
+// sends its event, err is the error it was released with if there was one, p.mu must not be
+// held
+func (p *ConnectionPool) reportEvict(pc *pooledConn, reason string, err error) {
+	p.mu.Lock()
+	p.lifetimes.observe(time.Since(pc.createdAt))
+	p.mu.Unlock()
+
+	p.emit(Event{Type: ConnEvicted, Address: pc.addr, ConnID: pc.id, Reason: reason, Err: err})
+	if p.log == nil {
+		return
+	}
+	attrs := []slog.Attr{slog.String("address", pc.addr), slog.Uint64("conn", pc.id), slog.String("reason", reason)}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	p.log.LogAttrs(context.Background(), slog.LevelInfo, "connection evicted", attrs...)
+}
+
+// contextAttrs returns the attributes Config.ContextAttrs extracts from ctx, p.mu must not be
+// held
+func (p *ConnectionPool) contextAttrs(ctx context.Context) []slog.Attr {
+	if p.Config.ContextAttrs == nil {
+		return nil
+	}
+	return p.Config.ContextAttrs(ctx)
+}
+
+// reportTimeout logs a Get call giving up after waiting, attrs are the Config.ContextAttrs of
+// the call. p.mu must not be held
+func (p *ConnectionPool) reportTimeout(ctx context.Context, waited time.Duration, attrs []slog.Attr) {
+	if p.log == nil {
+		return
+	}
+	p.mu.Lock()
+	addr := p.targetLocked()
+	p.mu.Unlock()
+	attrs = append([]slog.Attr{slog.String("address", addr), slog.Duration("waited", waited)}, attrs...)
+	p.log.LogAttrs(ctx, slog.LevelWarn, "timed out waiting for a connection", attrs...)
+}
+
+// reportSlowGet logs a warning with the pool's stats when a Get call waited for longer than
+// Config.SlowGetThreshold, attrs are the Config.ContextAttrs of the call. p.mu must not be held
+func (p *ConnectionPool) reportSlowGet(ctx context.Context, waited time.Duration, attrs []slog.Attr) {
+	threshold := p.Config.SlowGetThreshold
+	if threshold <= 0 || waited <= threshold {
+		return
+	}
+	logger := p.log
+	if logger == nil {
+		logger = slog.Default().With(slog.String("pool", p.Config.Name))
+	}
+	s := p.Stats()
+	attrs = append([]slog.Attr{
+		slog.Duration("waited", waited),
+		slog.Duration("threshold", threshold),
+		slog.Int("size", s.Size),
+		slog.Int("idle", s.Idle),
+		slog.Int("in_use", s.InUse),
+		slog.Int("waiters", s.Waiters),
+	}, attrs...)
+	logger.LogAttrs(ctx, slog.LevelWarn, "slow to get a connection", attrs...)
+}
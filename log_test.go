@@ -0,0 +1,165 @@
+package pool_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer that is safe to write to from the pool's goroutines
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestLoggerRecordsPoolActivity(t *testing.T) {
+	var out syncBuffer
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Name:          "bridge",
+		Address:       "10.0.0.5:23",
+		Size:          1,
+		RetryDuration: time.Millisecond,
+		Logger:        slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				return nil, errors.New("connection refused")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	_, err = p.Get(time.Millisecond, pool.GetOptions{})
+	require.ErrorIs(t, err, pool.ErrTimeout)
+	require.Nil(t, p.Release(c, errors.New("connection reset")))
+	<-p.Close()
+
+	logs := out.String()
+	require.Contains(t, logs, `level=WARN msg="connection attempt failed" pool=bridge address=10.0.0.5:23`)
+	require.Contains(t, logs, `error="connection refused"`)
+	require.Contains(t, logs, `level=DEBUG msg="connection established" pool=bridge address=10.0.0.5:23`)
+	require.Contains(t, logs, `level=WARN msg="timed out waiting for a connection" pool=bridge address=10.0.0.5:23`)
+	require.Contains(t, logs, `level=INFO msg="connection evicted" pool=bridge address=10.0.0.5:23 conn=1 reason="bad connection" error="connection reset"`)
+}
+
+func TestSlowGetIsLogged(t *testing.T) {
+	var out syncBuffer
+	p, err := pool.NewPool(pool.Config{
+		Name:             "bridge",
+		Size:             1,
+		SlowGetThreshold: 20 * time.Millisecond,
+		Logger:           slog.New(slog.NewTextHandler(&out, nil)),
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, c.Close())
+	require.NotContains(t, out.String(), "slow to get a connection")
+
+	c, err = p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	time.AfterFunc(50*time.Millisecond, func() { c.Close() })
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, c2.Close())
+	require.Contains(t, out.String(), `level=WARN msg="slow to get a connection" pool=bridge`)
+	require.Contains(t, out.String(), "threshold=20ms size=1 idle=0 in_use=1 waiters=0")
+}
+
+type requestIDKey struct{}
+
+func TestContextAttrsAreAddedToLogsAndEvents(t *testing.T) {
+	var out syncBuffer
+	p, err := pool.NewPool(pool.Config{
+		Name:   "bridge",
+		Size:   1,
+		Logger: slog.New(slog.NewTextHandler(&out, nil)),
+		ContextAttrs: func(ctx context.Context) []slog.Attr {
+			if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+				return []slog.Attr{slog.String("request_id", id)}
+			}
+			return nil
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+	events := p.Subscribe()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), requestIDKey{}, "req-42"), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.GetContext(ctx, pool.GetOptions{})
+	require.Equal(t, context.DeadlineExceeded, err)
+	require.Contains(t, out.String(), `msg="timed out waiting for a connection" pool=bridge`)
+	require.Contains(t, out.String(), "request_id=req-42")
+
+	e := <-events
+	require.Equal(t, pool.PoolExhausted, e.Type)
+	require.Equal(t, []slog.Attr{slog.String("request_id", "req-42")}, e.Attrs)
+}
+
+func TestLoggerRecordsLeaksAndPanics(t *testing.T) {
+	var out syncBuffer
+	p, err := pool.NewPool(pool.Config{
+		Name:        "bridge",
+		Size:        1,
+		LeakTimeout: 10 * time.Millisecond,
+		Logger:      slog.New(slog.NewTextHandler(&out, nil)),
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+		OnGet: func(time.Duration, bool) { panic("metrics backend down") },
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "connection checked out without being released")
+	}, time.Second, time.Millisecond)
+	require.Contains(t, out.String(), "callback=OnGet")
+	require.Contains(t, out.String(), `panic="metrics backend down"`)
+	require.Contains(t, out.String(), "pool=bridge")
+	require.Nil(t, p.Release(c, nil))
+}
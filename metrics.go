@@ -0,0 +1,69 @@
+package pool
+
+import "time"
+
+// MetricsSink receives the pool's metrics as they happen, so they can be sent to StatsD,
+// Datadog or any other system without the pool depending on a metrics library. The calls
+// are made from whichever goroutine caused the event and must not block. Every pool calls
+// the sink with the same names, so a sink shared by several pools should add the pool's name
+// itself, for example as a prefix or tag
+type MetricsSink interface {
+	// Count adds delta to the counter called name
+	Count(name string, delta int64)
+
+	// Gauge sets the gauge called name to value
+	Gauge(name string, value float64)
+
+	// Timing records a duration for the timer called name
+	Timing(name string, d time.Duration)
+}
+
+// The names of the metrics sent to Config.Metrics
+const (
+	// MetricDials counts connection attempts and MetricDialFailures the ones that failed,
+	// MetricDialTime is how long each attempt took
+	MetricDials        = "dials"
+	MetricDialFailures = "dial_failures"
+	MetricDialTime     = "dial_time"
+
+	// MetricWaitTime is how long each Get call waited for its connection and MetricTimeouts
+	// counts the Get calls that timed out instead
+	MetricWaitTime = "wait_time"
+	MetricTimeouts = "timeouts"
+
+	// MetricBadConns counts connections released with an error or marked as bad
+	MetricBadConns = "bad_conns"
+
+	// MetricIdle, MetricInUse and MetricWaiters are the number of idle connections, checked
+	// out connections and callers waiting for a connection, they are sent after each Get and
+	// Release
+	MetricIdle    = "idle"
+	MetricInUse   = "in_use"
+	MetricWaiters = "waiters"
+)
+
+func (p *ConnectionPool) count(name string, delta int64) {
+	if p.Config.Metrics != nil {
+		p.Config.Metrics.Count(name, delta)
+	}
+}
+
+func (p *ConnectionPool) timing(name string, d time.Duration) {
+	if p.Config.Metrics != nil {
+		p.Config.Metrics.Timing(name, d)
+	}
+}
+
+// reportGauges sends the idle, in use and waiter gauges, p.mu must not be held
+func (p *ConnectionPool) reportGauges() {
+	if p.Config.Metrics == nil {
+		return
+	}
+	p.mu.Lock()
+	idle, inUse, waiters := len(p.idle), len(p.inUse), len(p.waiters)
+	p.mu.Unlock()
+
+	p.Config.Metrics.Gauge(MetricIdle, float64(idle))
+	p.Config.Metrics.Gauge(MetricInUse, float64(inUse))
+	p.Config.Metrics.Gauge(MetricWaiters, float64(waiters))
+}
@@ -0,0 +1,91 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	mu      sync.Mutex
+	counts  map[string]int64
+	gauges  map[string]float64
+	timings map[string]int
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{counts: map[string]int64{}, gauges: map[string]float64{}, timings: map[string]int{}}
+}
+
+func (s *recordingSink) Count(name string, delta int64) {
+	s.mu.Lock()
+	s.counts[name] += delta
+	s.mu.Unlock()
+}
+
+func (s *recordingSink) Gauge(name string, value float64) {
+	s.mu.Lock()
+	s.gauges[name] = value
+	s.mu.Unlock()
+}
+
+func (s *recordingSink) Timing(name string, d time.Duration) {
+	s.mu.Lock()
+	s.timings[name]++
+	s.mu.Unlock()
+}
+
+func (s *recordingSink) count(name string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.counts[name]
+}
+
+func TestMetricsSinkReceivesEvents(t *testing.T) {
+	sink := newRecordingSink()
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Size:          2,
+		RetryDuration: time.Millisecond,
+		Metrics:       sink,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.AddInt32(&dials, 1) == 1 {
+				return nil, errors.New("connection refused")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	require.Equal(t, int64(3), sink.count(pool.MetricDials))
+	require.Equal(t, int64(1), sink.count(pool.MetricDialFailures))
+
+	c1, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	sink.mu.Lock()
+	require.Equal(t, 0.0, sink.gauges[pool.MetricIdle])
+	require.Equal(t, 2.0, sink.gauges[pool.MetricInUse])
+	require.Equal(t, 2, sink.timings[pool.MetricWaitTime])
+	require.Equal(t, 3, sink.timings[pool.MetricDialTime])
+	sink.mu.Unlock()
+
+	_, err = p.Get(time.Millisecond, pool.GetOptions{})
+	require.ErrorIs(t, err, pool.ErrTimeout)
+	require.Equal(t, int64(1), sink.count(pool.MetricTimeouts))
+
+	require.Nil(t, p.Release(c1, errors.New("connection reset")))
+	require.Nil(t, p.Release(c2, nil))
+	require.Equal(t, int64(1), sink.count(pool.MetricBadConns))
+	sink.mu.Lock()
+	require.Equal(t, 0.0, sink.gauges[pool.MetricInUse])
+	sink.mu.Unlock()
+	<-p.Close()
+}
@@ -0,0 +1,43 @@
+package pool
+
+import (
+	"context"
+	"time"
+)
+
+// monitor makes sure the pool has all of its connections each Config.MonitorInterval until
+// stop is closed
+func (p *ConnectionPool) monitor(stop chan struct{}) {
+	if p.Config.Lazy {
+		return
+	}
+
+	ticker := time.NewTicker(p.Config.MonitorInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		if p.Config.CheckHalfOpen {
+			p.mu.Lock()
+			idle := append([]*pooledConn(nil), p.idle...)
+			p.mu.Unlock()
+			for _, pc := range idle {
+				p.checkIdle(pc, "half open check", checkHalfOpen)
+			}
+		}
+
+		p.mu.Lock()
+		if p.unavailableLocked() == nil {
+			for p.healthyLocked()+p.pending < p.size {
+				p.dialLocked(context.Background(), dialHooks{reconnect: true})
+			}
+			p.topUpStandbyLocked()
+		}
+		p.mu.Unlock()
+	}
+}
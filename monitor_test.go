@@ -0,0 +1,39 @@
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitorRecreatesConnectionsThePoolGaveUpOn(t *testing.T) {
+	var down int32 = 1
+	p, err := pool.NewPool(pool.Config{
+		Size:            2,
+		MaxDialAttempts: 1,
+		MonitorInterval: 5 * time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if atomic.LoadInt32(&down) == 1 {
+				return nil, errors.New("connection refused")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	result := <-p.InitContext(context.Background())
+	require.True(t, errors.Is(result.Err, pool.ErrDialFailed))
+	require.Equal(t, 0, p.Stats().Idle)
+
+	atomic.StoreInt32(&down, 0)
+	require.Eventually(t, func() bool {
+		return p.Stats().Idle == 2
+	}, time.Second, time.Millisecond)
+	<-p.Close()
+}
@@ -0,0 +1,87 @@
+package pool
+
+import (
+	"net"
+	"sort"
+	"strings"
+	"time"
+)
+
+// watchNetwork checks the host's network interfaces each Config.NetworkCheckInterval until
+// stop is closed. When they change, for example because the host switched from Ethernet to
+// Wi-Fi, every connection is thrown away and replaced since the old sockets are unlikely to
+// work any more
+func (p *ConnectionPool) watchNetwork(stop chan struct{}) {
+	state := p.Config.NetworkState
+	if state == nil {
+		state = networkState
+	}
+	last, err := state()
+
+	ticker := time.NewTicker(p.Config.NetworkCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		current, cerr := state()
+		if cerr != nil {
+			continue
+		}
+		if err == nil && current != last {
+			p.flushNetwork()
+		}
+		last, err = current, nil
+	}
+}
+
+// flushNetwork throws away the standby connections and marks every connection in the pool
+// as bad so they are all replaced
+func (p *ConnectionPool) flushNetwork() {
+	p.mu.Lock()
+	standby := p.takeStandbyLocked()
+	p.mu.Unlock()
+	for _, pc := range standby {
+		p.closeConn(pc, closeNetworkChanged)
+	}
+
+	p.InvalidateAll()
+
+	p.mu.Lock()
+	if p.unavailableLocked() == nil {
+		p.topUpStandbyLocked()
+	}
+	p.mu.Unlock()
+}
+
+// networkState describes the interfaces that are up and their addresses, it is the default
+// Config.NetworkState
+func networkState() (string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+
+	var state []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return "", err
+		}
+		names := make([]string, len(addrs))
+		for i, addr := range addrs {
+			names[i] = addr.String()
+		}
+		sort.Strings(names)
+		state = append(state, iface.Name+"="+strings.Join(names, ","))
+	}
+	sort.Strings(state)
+	return strings.Join(state, " "), nil
+}
@@ -0,0 +1,51 @@
+package pool_test
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkChangeReplacesConnections(t *testing.T) {
+	var mu sync.Mutex
+	state := "eth0=192.168.1.10/24"
+	var dials, closed int32
+	p, err := pool.NewPool(pool.Config{
+		Size:                 2,
+		StandbyCount:         1,
+		NetworkCheckInterval: 5 * time.Millisecond,
+		NetworkState: func() (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return state, nil
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return &mockConn{CloseCalled: func(*mockConn) { atomic.AddInt32(&closed, 1) }}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&dials) == 3 }, time.Second, time.Millisecond)
+
+	// Nothing changes while the network stays the same
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, int32(3), atomic.LoadInt32(&dials))
+
+	mu.Lock()
+	state = "wlan0=192.168.1.23/24"
+	mu.Unlock()
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&dials) == 6 }, time.Second, time.Millisecond)
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&closed) == 3 }, time.Second, time.Millisecond)
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, nil))
+	<-p.Close()
+}
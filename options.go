@@ -0,0 +1,111 @@
+package pool
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"time"
+)
+
+const (
+	// DefaultSize is the number of connections used by New if WithSize is not specified
+	DefaultSize = 5
+
+	// DefaultRetryDuration is the retry duration used by New if WithRetry is not specified
+	DefaultRetryDuration = time.Second
+)
+
+// Option configures a pool created via New
+type Option func(*Config)
+
+// New creates a new ConnectionPool for the specified address, applying the default
+// settings and then each of the options in order. Unless WithDialFunc is specified
+// connections are opened with DefaultNewConnection. As with NewPool the returned pool
+// needs to have Init() called on it before it can be used
+func New(addr string, opts ...Option) (*ConnectionPool, error) {
+	config := Config{
+		Address:       addr,
+		Size:          DefaultSize,
+		RetryDuration: DefaultRetryDuration,
+		NewConnection: DefaultNewConnection,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return NewPool(config)
+}
+
+// WithName sets the friendly name of the pool
+func WithName(name string) Option {
+	return func(c *Config) {
+		c.Name = name
+	}
+}
+
+// WithSize sets the number of connections the pool opens
+func WithSize(size int) Option {
+	return func(c *Config) {
+		c.Size = size
+	}
+}
+
+// WithRetry sets how long the pool waits before retrying a failed connection attempt
+func WithRetry(d time.Duration) Option {
+	return func(c *Config) {
+		c.RetryDuration = d
+	}
+}
+
+// WithBackoff makes the pool back off exponentially between failed connection attempts
+// instead of waiting a fixed duration
+func WithBackoff(b Backoff) Option {
+	return func(c *Config) {
+		c.Backoff = &b
+	}
+}
+
+// WithExpvar publishes the pool's stats with the expvar package, see Config.Expvar
+func WithExpvar() Option {
+	return func(c *Config) {
+		c.Expvar = true
+	}
+}
+
+// WithDialFunc sets the function used to create new connections
+func WithDialFunc(dial func(Config) (net.Conn, error)) Option {
+	return func(c *Config) {
+		c.NewConnection = dial
+	}
+}
+
+// DefaultNewConnection opens a TCP connection to the Address in the config, through
+// Config.Proxy if it is set and with the dial settings from Config.DialTimeout, KeepAlive,
+// LocalAddr and FallbackDelay. If the address starts with tls://, or Config.TLS is set, the
+// connection uses TLS and the handshake is done before it is returned
+func DefaultNewConnection(c Config) (net.Conn, error) {
+	addr, useTLS := splitTLS(c)
+	var tlsConfig *tls.Config
+	if useTLS {
+		var err error
+		if tlsConfig, err = c.TLS.clientConfig(addr); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx := context.Background()
+	if c.DialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.DialTimeout)
+		defer cancel()
+	}
+	conn, err := dialTCP(ctx, c, addr)
+	if err != nil || !useTLS {
+		return conn, err
+	}
+	tc := tls.Client(conn, tlsConfig)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
@@ -0,0 +1,56 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAppliesDefaultsAndOptions(t *testing.T) {
+	p, err := pool.New("10.0.0.5:23")
+	require.Nil(t, err)
+	require.Equal(t, "10.0.0.5:23", p.Config.Address)
+	require.Equal(t, pool.DefaultSize, p.Config.Size)
+	require.Equal(t, pool.DefaultRetryDuration, p.Config.RetryDuration)
+
+	p, err = pool.New("10.0.0.5:23",
+		pool.WithName("bridge"),
+		pool.WithSize(2),
+		pool.WithRetry(time.Millisecond),
+		pool.WithDialFunc(func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		}),
+	)
+	require.Nil(t, err)
+	require.Equal(t, "bridge", p.Config.Name)
+	require.Equal(t, 2, p.Config.Size)
+	require.Equal(t, time.Millisecond, p.Config.RetryDuration)
+}
+
+func TestNewValidatesOptions(t *testing.T) {
+	p, err := pool.New("10.0.0.5:23", pool.WithSize(0))
+	require.Nil(t, p)
+	require.True(t, errors.Is(err, pool.ErrInvalidConfig))
+}
+
+func TestDefaultNewConnectionDialsAddress(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+
+	p, err := pool.New(l.Addr().String(), pool.WithSize(1))
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Equal(t, l.Addr().String(), c.RemoteAddr().String())
+	p.Release(c, nil)
+	<-p.Close()
+}
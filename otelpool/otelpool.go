@@ -0,0 +1,206 @@
+// Package otelpool traces getting and releasing pool connections with OpenTelemetry. It is
+// kept separate from the pool package so the pool itself doesn't depend on OpenTelemetry
+package otelpool
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentation is the name the spans are recorded under
+const instrumentation = "github.com/go-home-iot/connection-pool/otelpool"
+
+// Pool wraps a ConnectionPool, recording a "pool.Get" span for every connection checked out
+// and a "pool.Release" span every time one is given back, whether with Release or the
+// lease's Close or Discard. Only the traced methods are provided, Unwrap returns the
+// ConnectionPool for everything else
+type Pool struct {
+	pool   *pool.ConnectionPool
+	tracer trace.Tracer
+
+	// releasing holds the spans of the Release calls in progress, by lease, so the
+	// AfterRelease hook records into them rather than starting a span of its own
+	releasing sync.Map
+}
+
+var _ pool.Pooler = (*pooler)(nil)
+
+// Option configures a Pool created by New
+type Option func(*options)
+
+type options struct {
+	provider trace.TracerProvider
+}
+
+// WithTracerProvider sets the provider the spans are created with, by default the global
+// provider from otel.GetTracerProvider is used
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *options) {
+		o.provider = tp
+	}
+}
+
+// New returns a Pool that traces p
+func New(p *pool.ConnectionPool, opts ...Option) *Pool {
+	o := options{provider: otel.GetTracerProvider()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Pool{pool: p, tracer: o.provider.Tracer(instrumentation)}
+}
+
+// Unwrap returns the ConnectionPool being traced
+func (p *Pool) Unwrap() *pool.ConnectionPool {
+	return p.pool
+}
+
+// Init starts the pool like pool.ConnectionPool.Init
+func (p *Pool) Init() chan bool {
+	return p.pool.Init()
+}
+
+// Close closes the pool like pool.ConnectionPool.Close
+func (p *Pool) Close() chan bool {
+	return p.pool.Close()
+}
+
+// Stats returns the pool's stats like pool.ConnectionPool.Stats
+func (p *Pool) Stats() pool.Stats {
+	return p.pool.Stats()
+}
+
+// Pooler returns the traced pool as a pool.Pooler, for code that depends on the interface.
+// Connections released through it are traced as children of the context they were got with
+func (p *Pool) Pooler() pool.Pooler {
+	return &pooler{p}
+}
+
+// Get gets a connection like pool.ConnectionPool.Get inside a "pool.Get" span
+func (p *Pool) Get(timeout time.Duration, opts pool.GetOptions) (*pool.Connection, error) {
+	return p.get(context.Background(), opts, func(ctx context.Context, opts pool.GetOptions) (*pool.Connection, error) {
+		return p.pool.Get(timeout, opts)
+	})
+}
+
+// GetUntil gets a connection like pool.ConnectionPool.GetUntil inside a "pool.Get" span
+func (p *Pool) GetUntil(deadline time.Time, opts pool.GetOptions) (*pool.Connection, error) {
+	return p.get(context.Background(), opts, func(ctx context.Context, opts pool.GetOptions) (*pool.Connection, error) {
+		return p.pool.GetUntil(deadline, opts)
+	})
+}
+
+// GetContext gets a connection like pool.ConnectionPool.GetContext inside a "pool.Get" span
+// that is a child of the span in ctx
+func (p *Pool) GetContext(ctx context.Context, opts pool.GetOptions) (*pool.Connection, error) {
+	return p.get(ctx, opts, p.pool.GetContext)
+}
+
+// TryGet gets an idle connection like pool.ConnectionPool.TryGet inside a "pool.Get" span
+func (p *Pool) TryGet() (*pool.Connection, error) {
+	return p.get(context.Background(), pool.GetOptions{}, func(context.Context, pool.GetOptions) (*pool.Connection, error) {
+		return p.pool.TryGet()
+	})
+}
+
+// get calls get inside a "pool.Get" span, recording how long the call waited and whether a
+// connection had to be created for it, and arranges for the connection's release to be
+// traced. The wait and dial are reported through GetOptions.Trace, except by TryGet which
+// never waits or dials
+func (p *Pool) get(ctx context.Context, opts pool.GetOptions, get func(context.Context, pool.GetOptions) (*pool.Connection, error)) (*pool.Connection, error) {
+	start := time.Now()
+	spanCtx, span := p.tracer.Start(ctx, "pool.Get",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(p.attributes()...))
+	defer span.End()
+
+	var info *pool.GetInfo
+	callerTrace := opts.Trace
+	opts.Trace = &pool.GetTrace{
+		Done: func(i pool.GetInfo) {
+			info = &i
+			if callerTrace != nil && callerTrace.Done != nil {
+				callerTrace.Done(i)
+			}
+		},
+	}
+	c, err := get(spanCtx, opts)
+	if info == nil {
+		info = &pool.GetInfo{Waited: time.Since(start), Err: err}
+	}
+	span.SetAttributes(
+		attribute.Int64("pool.wait_ms", info.Waited.Milliseconds()),
+		attribute.Bool("pool.dialed", info.Dialed),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	c.AfterRelease(func(err error) { p.released(ctx, c, err) })
+	return c, nil
+}
+
+// released records the release of c. If it was released with Release the span of that call
+// is used, otherwise, as for Close and Discard, a span is recorded as a child of the context
+// c was got with
+func (p *Pool) released(ctx context.Context, c *pool.Connection, err error) {
+	if span, ok := p.releasing.Load(c); ok {
+		p.annotateRelease(span.(trace.Span), err)
+		return
+	}
+	_, span := p.tracer.Start(ctx, "pool.Release",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(p.attributes()...))
+	p.annotateRelease(span, err)
+	span.End()
+}
+
+// annotateRelease records the error a connection was released with on its span
+func (p *Pool) annotateRelease(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetAttributes(attribute.Bool("pool.bad_conn", true))
+	}
+}
+
+// Release returns the connection like pool.ConnectionPool.Release inside a "pool.Release"
+// span, recording the error the connection is released with
+func (p *Pool) Release(ctx context.Context, c *pool.Connection, err error) error {
+	_, span := p.tracer.Start(ctx, "pool.Release",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(p.attributes()...))
+	defer span.End()
+
+	p.releasing.Store(c, span)
+	rerr := p.pool.Release(c, err)
+	p.releasing.Delete(c)
+	if rerr != nil {
+		span.RecordError(rerr)
+		span.SetStatus(codes.Error, rerr.Error())
+	}
+	return rerr
+}
+
+func (p *Pool) attributes() []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("pool.name", p.pool.Config.Name),
+		attribute.String("pool.address", p.pool.ActiveAddress()),
+	}
+}
+
+// pooler adapts Pool to pool.Pooler, whose Release has no context
+type pooler struct {
+	*Pool
+}
+
+func (p *pooler) Release(c *pool.Connection, err error) error {
+	return p.Pool.Release(context.Background(), c, err)
+}
@@ -0,0 +1,146 @@
+package otelpool_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/go-home-iot/connection-pool/otelpool"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type mockConn struct {
+	net.Conn
+}
+
+func (c *mockConn) Close() error {
+	return nil
+}
+
+// newPool returns a traced pool of one connection and the recorder its spans end up in
+func newPool(t *testing.T) (*otelpool.Pool, *tracetest.SpanRecorder) {
+	cp, err := pool.NewPool(pool.Config{
+		Name: "bridge",
+		Size: 1,
+		NewConnection: func(pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-cp.Init()
+	t.Cleanup(func() { <-cp.Close() })
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return otelpool.New(cp, otelpool.WithTracerProvider(tp)), recorder
+}
+
+// spanNames returns the names of spans, in the order they ended
+func spanNames(spans []sdktrace.ReadOnlySpan) []string {
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name())
+	}
+	return names
+}
+
+func attributes(s sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestEveryCheckoutIsTraced(t *testing.T) {
+	p, recorder := newPool(t)
+	gets := map[string]func() (*pool.Connection, error){
+		"Get": func() (*pool.Connection, error) {
+			return p.Get(time.Second, pool.GetOptions{})
+		},
+		"GetUntil": func() (*pool.Connection, error) {
+			return p.GetUntil(time.Now().Add(time.Second), pool.GetOptions{})
+		},
+		"GetContext": func() (*pool.Connection, error) {
+			return p.GetContext(context.Background(), pool.GetOptions{})
+		},
+		"TryGet": p.TryGet,
+	}
+	for name, get := range gets {
+		ended := len(recorder.Ended())
+		c, err := get()
+		require.Nil(t, err, name)
+		require.Nil(t, p.Release(context.Background(), c, nil), name)
+		spans := recorder.Ended()[ended:]
+		require.Equal(t, []string{"pool.Get", "pool.Release"}, spanNames(spans), name)
+
+		s := spans[0]
+		attrs := attributes(s)
+		require.Equal(t, "bridge", attrs["pool.name"].AsString(), name)
+		require.Contains(t, attrs, attribute.Key("pool.wait_ms"), name)
+		require.Contains(t, attrs, attribute.Key("pool.dialed"), name)
+		require.False(t, attrs["pool.dialed"].AsBool(), name)
+		require.Equal(t, codes.Unset, s.Status().Code, name)
+	}
+}
+
+func TestFailedGetsAreMarkedAsErrors(t *testing.T) {
+	p, recorder := newPool(t)
+	c, err := p.TryGet()
+	require.Nil(t, err)
+
+	_, err = p.Get(10*time.Millisecond, pool.GetOptions{})
+	require.ErrorIs(t, err, pool.ErrTimeout)
+	_, err = p.TryGet()
+	require.ErrorIs(t, err, pool.ErrExhausted)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	for _, s := range spans[1:] {
+		require.Equal(t, "pool.Get", s.Name())
+		require.Equal(t, codes.Error, s.Status().Code)
+		require.Len(t, s.Events(), 1)
+		require.Equal(t, "exception", s.Events()[0].Name)
+	}
+	require.GreaterOrEqual(t, attributes(spans[1])["pool.wait_ms"].AsInt64(), int64(10))
+	require.Nil(t, p.Release(context.Background(), c, nil))
+}
+
+func TestClosingOrDiscardingTheLeaseIsTraced(t *testing.T) {
+	p, recorder := newPool(t)
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, c.Close())
+	spans := recorder.Ended()
+	require.Equal(t, []string{"pool.Get", "pool.Release"}, spanNames(spans))
+	require.NotContains(t, attributes(spans[1]), attribute.Key("pool.bad_conn"))
+	require.Equal(t, spans[0].Parent(), spans[1].Parent())
+
+	c, err = p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, c.Discard())
+	spans = recorder.Ended()[2:]
+	require.Equal(t, []string{"pool.Get", "pool.Release"}, spanNames(spans))
+	require.True(t, attributes(spans[1])["pool.bad_conn"].AsBool())
+}
+
+func TestReleaseIsRecordedOnce(t *testing.T) {
+	p, recorder := newPool(t)
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(context.Background(), c, errors.New("reset")))
+	require.Equal(t, pool.ErrAlreadyReleased, p.Release(context.Background(), c, nil))
+
+	spans := recorder.Ended()
+	require.Equal(t, []string{"pool.Get", "pool.Release", "pool.Release"}, spanNames(spans))
+	require.True(t, attributes(spans[1])["pool.bad_conn"].AsBool())
+	require.Equal(t, codes.Unset, spans[1].Status().Code)
+	require.Equal(t, codes.Error, spans[2].Status().Code)
+}
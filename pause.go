@@ -0,0 +1,25 @@
+package pool
+
+// Pause stops the pool handing out connections without closing any of them, for example
+// while a device is having its firmware updated. Get calls wait until the pool is resumed or
+// they time out, TryGet and Get calls with GetOptions.NoWait set return ErrPaused. Calling
+// Pause on a pool that is already paused has no effect
+func (p *ConnectionPool) Pause() {
+	p.mu.Lock()
+	p.paused = true
+	p.mu.Unlock()
+}
+
+// Resume starts handing out connections again after a call to Pause, callers that have been
+// waiting while the pool was paused are served in their usual order
+func (p *ConnectionPool) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		return
+	}
+	p.paused = false
+	p.serveLocked()
+	p.growLocked()
+}
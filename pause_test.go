@@ -0,0 +1,51 @@
+package pool_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPausedPoolHoldsGetsUntilResumed(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	p.Pause()
+	_, err = p.TryGet()
+	require.Equal(t, pool.ErrPaused, err)
+	_, err = p.Get(time.Second, pool.GetOptions{NoWait: true})
+	require.Equal(t, pool.ErrPaused, err)
+	_, err = p.Get(10*time.Millisecond, pool.GetOptions{})
+	require.ErrorIs(t, err, pool.ErrTimeout)
+	require.Equal(t, 1, p.Stats().Idle)
+
+	type result struct {
+		conn *pool.Connection
+		err  error
+	}
+	got := make(chan result, 1)
+	go func() {
+		c, err := p.Get(time.Second, pool.GetOptions{})
+		got <- result{c, err}
+	}()
+	require.Eventually(t, func() bool {
+		return p.Dump().Waiters == 1
+	}, time.Second, time.Millisecond)
+
+	p.Resume()
+	r := <-got
+	require.Nil(t, r.err)
+	require.Nil(t, p.Release(r.conn, nil))
+	<-p.Close()
+}
@@ -0,0 +1,16 @@
+package pool
+
+import "time"
+
+// Pooler is the set of operations provided by a connection pool. ConnectionPool implements
+// it, consumers should depend on Pooler rather than *ConnectionPool so that the pool can be
+// mocked in unit tests or swapped for a different implementation
+type Pooler interface {
+	Init() chan bool
+	Get(timeout time.Duration, opts GetOptions) (*Connection, error)
+	Release(c *Connection, err error) error
+	Close() chan bool
+	Stats() Stats
+}
+
+var _ Pooler = (*ConnectionPool)(nil)
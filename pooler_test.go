@@ -0,0 +1,96 @@
+package pool_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+// singlePool is a Pooler that hands out the same connection to one caller at a time
+type singlePool struct {
+	conn     net.Conn
+	leased   bool
+	released []error
+}
+
+func (p *singlePool) Init() chan bool {
+	done := make(chan bool, 1)
+	done <- true
+	return done
+}
+
+func (p *singlePool) Get(timeout time.Duration, opts pool.GetOptions) (*pool.Connection, error) {
+	if p.leased {
+		return nil, pool.ErrTimeout
+	}
+	p.leased = true
+	return pool.NewConnection(p.conn, p), nil
+}
+
+func (p *singlePool) Release(c *pool.Connection, err error) error {
+	p.leased = false
+	p.released = append(p.released, err)
+	return nil
+}
+
+func (p *singlePool) Close() chan bool {
+	return p.Init()
+}
+
+func (p *singlePool) Stats() pool.Stats {
+	s := pool.Stats{Size: 1, Idle: 1}
+	if p.leased {
+		s.Idle, s.InUse = 0, 1
+	}
+	return s
+}
+
+// sendCommand is an example of code that depends on a Pooler rather than a ConnectionPool
+func sendCommand(p pool.Pooler) error {
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	_, err = c.Write([]byte("on"))
+	return err
+}
+
+func TestPoolerCanBeFaked(t *testing.T) {
+	closeCalled := false
+	fake := &singlePool{conn: &writeConn{mockConn: mockConn{
+		CloseCalled: func(c *mockConn) {
+			closeCalled = true
+		},
+	}}}
+
+	require.Nil(t, sendCommand(fake))
+	require.Equal(t, []error{nil}, fake.released)
+	require.False(t, fake.leased)
+	require.False(t, closeCalled)
+}
+
+func TestConnectionWithoutOwnerClosesUnderlyingConn(t *testing.T) {
+	closeCalled := false
+	c := pool.NewConnection(&mockConn{
+		CloseCalled: func(c *mockConn) {
+			closeCalled = true
+		},
+	}, nil)
+
+	require.Nil(t, c.Close())
+	require.True(t, closeCalled)
+}
+
+// writeConn is a mockConn that accepts writes
+type writeConn struct {
+	mockConn
+}
+
+func (c *writeConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
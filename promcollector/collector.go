@@ -0,0 +1,133 @@
+// Package promcollector exports the state of connection pools to Prometheus. It is kept
+// separate from the pool package so the pool itself doesn't depend on the Prometheus client
+package promcollector
+
+import (
+	"sync"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "connection_pool"
+
+var (
+	labels = []string{"name", "address"}
+
+	sizeDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "size"),
+		"The configured number of connections in the pool.", labels, nil)
+	idleDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "idle_connections"),
+		"The number of connections waiting in the pool to be used.", labels, nil)
+	inUseDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "in_use_connections"),
+		"The number of connections checked out of the pool.", labels, nil)
+	standbyDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "standby_connections"),
+		"The number of standby connections ready to replace ones that fail.", labels, nil)
+	waitersDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "waiters"),
+		"The number of callers waiting for a connection.", labels, nil)
+	addressDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "address_connections"),
+		"The number of open connections to each address of a pool using Config.Addresses.", labels, nil)
+
+	dialsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "dials_total"),
+		"The number of connection attempts made.", labels, nil)
+	dialFailuresDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "dial_failures_total"),
+		"The number of connection attempts that failed.", labels, nil)
+	timeoutsDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "timeouts_total"),
+		"The number of Get calls that timed out waiting for a connection.", labels, nil)
+
+	waitDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "wait_seconds"),
+		"How long Get calls waited for the connections they got.", labels, nil)
+	dialDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "dial_seconds"),
+		"How long connection attempts took.", labels, nil)
+	lifetimeDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "connection_lifetime_seconds"),
+		"How long connections were open for before they were evicted.", labels, nil)
+)
+
+// Collector is a prometheus.Collector reporting the Stats of a set of pools, every metric is
+// labeled with the pool's Config.Name and the address it is connecting to
+type Collector struct {
+	mu    sync.Mutex
+	pools []*pool.ConnectionPool
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// New returns a Collector for the pools, more can be added later with Add
+func New(pools ...*pool.ConnectionPool) *Collector {
+	return &Collector{pools: pools}
+}
+
+// Add starts reporting the pool's metrics
+func (c *Collector) Add(p *pool.ConnectionPool) {
+	c.mu.Lock()
+	c.pools = append(c.pools, p)
+	c.mu.Unlock()
+}
+
+// Remove stops reporting the pool's metrics, for example once it has been closed
+func (c *Collector) Remove(p *pool.ConnectionPool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, cp := range c.pools {
+		if cp == p {
+			c.pools = append(c.pools[:i], c.pools[i+1:]...)
+			return
+		}
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{
+		sizeDesc, idleDesc, inUseDesc, standbyDesc, waitersDesc, addressDesc,
+		dialsDesc, dialFailuresDesc, timeoutsDesc, waitDesc, dialDesc, lifetimeDesc,
+	} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	pools := append([]*pool.ConnectionPool(nil), c.pools...)
+	c.mu.Unlock()
+
+	for _, p := range pools {
+		s := p.Stats()
+		lv := []string{p.Config.Name, p.ActiveAddress()}
+
+		gauge := func(d *prometheus.Desc, v int) {
+			ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, float64(v), lv...)
+		}
+		gauge(sizeDesc, s.Size)
+		gauge(idleDesc, s.Idle)
+		gauge(inUseDesc, s.InUse)
+		gauge(standbyDesc, s.Standby)
+		gauge(waitersDesc, s.Waiters)
+		for addr, n := range s.Addresses {
+			ch <- prometheus.MustNewConstMetric(addressDesc, prometheus.GaugeValue, float64(n), p.Config.Name, addr)
+		}
+
+		counter := func(d *prometheus.Desc, v int64) {
+			ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, float64(v), lv...)
+		}
+		counter(dialsDesc, s.Dials)
+		counter(dialFailuresDesc, s.DialFailures)
+		counter(timeoutsDesc, s.Timeouts)
+
+		ch <- histogram(waitDesc, s.WaitTimes, lv)
+		ch <- histogram(dialDesc, s.DialTimes, lv)
+		ch <- histogram(lifetimeDesc, s.Lifetimes, lv)
+	}
+}
+
+// histogram converts a pool.Histogram, which counts each bucket separately, to a Prometheus
+// histogram with cumulative buckets in seconds
+func histogram(d *prometheus.Desc, h pool.Histogram, lv []string) prometheus.Metric {
+	buckets := make(map[float64]uint64, len(h.Buckets))
+	var total uint64
+	for i, b := range h.Buckets {
+		total += uint64(h.Counts[i])
+		buckets[b.Seconds()] = total
+	}
+	return prometheus.MustNewConstHistogram(d, uint64(h.Count), h.Sum.Seconds(), buckets, lv...)
+}
@@ -0,0 +1,62 @@
+package promcollector_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/go-home-iot/connection-pool/promcollector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+type mockConn struct {
+	net.Conn
+}
+
+func (c *mockConn) Close() error {
+	return nil
+}
+
+func newPool(t *testing.T, cfg pool.Config) *pool.ConnectionPool {
+	cfg.NewConnection = func(pool.Config) (net.Conn, error) {
+		return &mockConn{}, nil
+	}
+	p, err := pool.NewPool(cfg)
+	require.Nil(t, err)
+	<-p.Init()
+	return p
+}
+
+func collect(c prometheus.Collector) []prometheus.Metric {
+	ch := make(chan prometheus.Metric, 100)
+	c.Collect(ch)
+	close(ch)
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func TestCollectorReportsEachPool(t *testing.T) {
+	bridge := newPool(t, pool.Config{Name: "bridge", Size: 2, Address: "10.0.0.5:23"})
+	defer bridge.Close()
+	hub := newPool(t, pool.Config{Name: "hub", Size: 2, Addresses: []string{"10.0.0.6:23", "10.0.0.6:24"}})
+	defer hub.Close()
+
+	c := promcollector.New(bridge)
+	require.Len(t, collect(c), 11)
+
+	// Pools using Addresses also report the connections to each address
+	c.Add(hub)
+	require.Len(t, collect(c), 24)
+
+	c.Remove(bridge)
+	require.Len(t, collect(c), 13)
+
+	descs := make(chan *prometheus.Desc, 100)
+	c.Describe(descs)
+	close(descs)
+	require.Len(t, descs, 12)
+}
@@ -0,0 +1,245 @@
+package pool
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ProxyURL returns a Config.Proxy that sends every connection through the proxy at u
+func ProxyURL(u *url.URL) func(addr string) (*url.URL, error) {
+	return func(string) (*url.URL, error) {
+		return u, nil
+	}
+}
+
+// ProxyFromEnvironment is a Config.Proxy that uses the proxy in the HTTPS_PROXY environment
+// variable, or https_proxy, unless the address is excluded by NO_PROXY, the same way
+// http.ProxyFromEnvironment does for HTTPS requests. As there, the environment is only read
+// the first time it is used
+func ProxyFromEnvironment(addr string) (*url.URL, error) {
+	return http.ProxyFromEnvironment(&http.Request{URL: &url.URL{Scheme: "https", Host: addr}})
+}
+
+// dialTCP opens a TCP connection to addr, through the proxy from Config.Proxy if there is one.
+// The handshake with the proxy must finish before the context's deadline
+func dialTCP(ctx context.Context, c Config, addr string) (net.Conn, error) {
+	d, err := netDialer(c)
+	if err != nil {
+		return nil, err
+	}
+	if c.Proxy == nil {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	u, err := c.Proxy(addr)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	if u == nil {
+		return d.DialContext(ctx, "tcp", addr)
+	}
+
+	var connect func(net.Conn, *url.URL, string) (net.Conn, error)
+	port := ""
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		connect, port = socks5Connect, "1080"
+	case "http":
+		connect, port = httpConnect, "80"
+	default:
+		return nil, fmt.Errorf("proxy: unsupported scheme %q", u.Scheme)
+	}
+	proxyAddr := u.Host
+	if u.Port() == "" {
+		proxyAddr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	conn, err := d.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("proxy %s: %w", u.Redacted(), err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tunnel, err := connect(conn, u, addr)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("proxy %s: %w", u.Redacted(), err)
+	}
+	conn.SetDeadline(time.Time{})
+	return tunnel, nil
+}
+
+// socks5Replies are the messages for the SOCKS5 reply codes, from RFC 1928
+var socks5Replies = []string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// socks5Connect asks the SOCKS5 proxy on conn to connect to addr, authenticating with the
+// user name and password in u if it has them. The host name is passed to the proxy to look
+// up, as devices on an isolated network often can only be resolved from there
+func socks5Connect(conn net.Conn, u *url.URL, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	greeting := []byte{5, 1, 0}
+	if u.User != nil {
+		greeting = []byte{5, 2, 0, 2}
+	}
+	if _, err := conn.Write(greeting); err != nil {
+		return nil, err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return nil, err
+	}
+	if reply[0] != 5 {
+		return nil, fmt.Errorf("not a SOCKS5 proxy, got version %d", reply[0])
+	}
+	switch reply[1] {
+	case 0:
+	case 2:
+		if u.User == nil {
+			return nil, errors.New("proxy requires a user name and password")
+		}
+		user := u.User.Username()
+		pass, _ := u.User.Password()
+		if len(user) > 255 || len(pass) > 255 {
+			return nil, errors.New("user name and password must be at most 255 bytes")
+		}
+		auth := append([]byte{1, byte(len(user))}, user...)
+		auth = append(append(auth, byte(len(pass))), pass...)
+		if _, err := conn.Write(auth); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(conn, reply[:]); err != nil {
+			return nil, err
+		}
+		if reply[1] != 0 {
+			return nil, errors.New("user name and password rejected")
+		}
+	default:
+		return nil, errors.New("no acceptable authentication method")
+	}
+
+	req := []byte{5, 1, 0}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		req = append(append(req, 1), ip.To4()...)
+	} else if ip != nil {
+		req = append(append(req, 4), ip.To16()...)
+	} else {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("host name %q is too long", host)
+		}
+		req = append(append(req, 3, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return nil, err
+	}
+	if head[1] != 0 {
+		if int(head[1]) < len(socks5Replies) {
+			return nil, errors.New(socks5Replies[head[1]])
+		}
+		return nil, fmt.Errorf("unknown SOCKS5 reply %d", head[1])
+	}
+	// Skip the address the proxy connected from and its port
+	var skip int
+	switch head[3] {
+	case 1:
+		skip = net.IPv4len + 2
+	case 4:
+		skip = net.IPv6len + 2
+	case 3:
+		var n [1]byte
+		if _, err := io.ReadFull(conn, n[:]); err != nil {
+			return nil, err
+		}
+		skip = int(n[0]) + 2
+	default:
+		return nil, fmt.Errorf("unknown SOCKS5 address type %d", head[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, skip)); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// httpConnect asks the HTTP proxy on conn to open a tunnel to addr with a CONNECT request,
+// authenticating with the user name and password in u if it has them
+func httpConnect(conn net.Conn, u *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("CONNECT %s: %s", addr, resp.Status)
+	}
+	if r.Buffered() > 0 {
+		// The device spoke first and some of what it sent was read with the response
+		return &prereadConn{Conn: conn, r: r}, nil
+	}
+	return conn, nil
+}
+
+// prereadConn is a connection with data that has already been read into r
+type prereadConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *prereadConn) Read(b []byte) (int, error) {
+	if c.r.Buffered() > 0 {
+		return c.r.Read(b)
+	}
+	return c.Conn.Read(b)
+}
+
+// NetConn returns the connection to the proxy
+func (c *prereadConn) NetConn() net.Conn {
+	return c.Conn
+}
@@ -0,0 +1,176 @@
+package pool_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+// echoServer starts a server that writes back whatever it is sent, returning its port
+func echoServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(c, c)
+				c.Close()
+			}()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return port
+}
+
+// splice copies between the two connections until either is closed
+func splice(a, b net.Conn) {
+	go func() {
+		io.Copy(a, b)
+		a.Close()
+	}()
+	io.Copy(b, a)
+	b.Close()
+}
+
+// socks5Server starts a SOCKS5 proxy that requires the user name and password, sending the
+// address of each connection it is asked for on requested
+func socks5Server(t *testing.T, user, pass string, requested chan<- string) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				r := bufio.NewReader(c)
+				head := make([]byte, 2)
+				io.ReadFull(r, head)
+				io.ReadFull(r, make([]byte, head[1]))
+				c.Write([]byte{5, 2})
+
+				readString := func() string {
+					n, _ := r.ReadByte()
+					b := make([]byte, n)
+					io.ReadFull(r, b)
+					return string(b)
+				}
+				r.ReadByte()
+				if readString() != user || readString() != pass {
+					c.Write([]byte{1, 1})
+					return
+				}
+				c.Write([]byte{1, 0})
+
+				req := make([]byte, 4)
+				io.ReadFull(r, req)
+				host := readString()
+				port := make([]byte, 2)
+				io.ReadFull(r, port)
+				addr := net.JoinHostPort(host, fmt.Sprint(binary.BigEndian.Uint16(port)))
+				requested <- addr
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				c.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+				splice(c, target)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func echo(t *testing.T, c net.Conn) {
+	_, err := c.Write([]byte("ping"))
+	require.Nil(t, err)
+	b := make([]byte, 4)
+	_, err = io.ReadFull(c, b)
+	require.Nil(t, err)
+	require.Equal(t, "ping", string(b))
+}
+
+func TestPoolDialsThroughSOCKS5Proxy(t *testing.T) {
+	port := echoServer(t)
+	requested := make(chan string, 1)
+	proxy := socks5Server(t, "hub", "secret", requested)
+
+	p, err := pool.NewPool(pool.Config{
+		Size:    1,
+		Address: "localhost:" + port,
+		Proxy:   pool.ProxyURL(&url.URL{Scheme: "socks5", Host: proxy, User: url.UserPassword("hub", "secret")}),
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	// The host name is looked up by the proxy, not here
+	require.Equal(t, "localhost:"+port, <-requested)
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	echo(t, c)
+	require.Nil(t, p.Release(c, nil))
+
+	_, err = pool.DefaultNewConnection(pool.Config{
+		Address: "localhost:" + port,
+		Proxy:   pool.ProxyURL(&url.URL{Scheme: "socks5", Host: proxy, User: url.UserPassword("hub", "wrong")}),
+	})
+	require.ErrorContains(t, err, "user name and password rejected")
+}
+
+func TestDefaultNewConnectionUsesHTTPConnectProxy(t *testing.T) {
+	port := echoServer(t)
+	auth := make(chan string, 2)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth <- r.Header.Get("Proxy-Authorization")
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		fmt.Fprint(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		splice(conn, target)
+	}))
+	defer proxy.Close()
+
+	proxyURL, err := url.Parse(proxy.URL)
+	require.Nil(t, err)
+	proxyURL.User = url.UserPassword("hub", "secret")
+	c, err := pool.DefaultNewConnection(pool.Config{Address: "127.0.0.1:" + port, Proxy: pool.ProxyURL(proxyURL)})
+	require.Nil(t, err)
+	echo(t, c)
+	c.Close()
+	require.Equal(t, "Basic aHViOnNlY3JldA==", <-auth)
+
+	_, err = pool.DefaultNewConnection(pool.Config{Address: "127.0.0.1:1", Proxy: pool.ProxyURL(proxyURL)})
+	require.ErrorContains(t, err, "502 Bad Gateway")
+	require.NotContains(t, err.Error(), "secret")
+}
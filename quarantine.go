@@ -0,0 +1,100 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Quarantine stops the pool connecting to an address for Period once Failures of its
+// connections have been marked as bad within Window, instead of endlessly reconnecting to a
+// device that keeps dropping connections. Connection attempts wait until the quarantine is
+// over. OnQuarantine if set is called from a new goroutine each time an address is quarantined
+type Quarantine struct {
+	Failures int
+	Window   time.Duration
+	Period   time.Duration
+
+	OnQuarantine func(addr string, until time.Time)
+}
+
+func (q Quarantine) validate() error {
+	switch {
+	case q.Failures <= 0:
+		return errors.New("Failures must be greater than 0")
+	case q.Window <= 0:
+		return errors.New("Window must be greater than 0")
+	case q.Period <= 0:
+		return errors.New("Period must be greater than 0")
+	}
+	return nil
+}
+
+// quarantineLocked records that the connection has been marked as bad, quarantining its
+// address if that has happened too often, p.mu must be held
+func (p *ConnectionPool) quarantineLocked(pc *pooledConn) {
+	q := p.Config.Quarantine
+	if q == nil {
+		return
+	}
+	if p.badMarks == nil {
+		p.badMarks = make(map[string][]time.Time)
+		p.quarantined = make(map[string]time.Time)
+	}
+
+	now := time.Now()
+	marks := p.badMarks[pc.addr]
+	for len(marks) > 0 && now.Sub(marks[0]) > q.Window {
+		marks = marks[1:]
+	}
+	marks = append(marks, now)
+	if len(marks) < q.Failures {
+		p.badMarks[pc.addr] = marks
+		return
+	}
+
+	delete(p.badMarks, pc.addr)
+	until := now.Add(q.Period)
+	p.quarantined[pc.addr] = until
+	if q.OnQuarantine != nil {
+		addr := pc.addr
+		go p.safely("OnQuarantine", func() { q.OnQuarantine(addr, until) })
+	}
+}
+
+// waitQuarantine blocks while every address the pool connects to is quarantined. It returns
+// false if the context is done or stop is closed first
+func (p *ConnectionPool) waitQuarantine(ctx context.Context, stop chan struct{}) bool {
+	for {
+		p.mu.Lock()
+		var until time.Time
+		ok := true
+		for _, addr := range p.addressesLocked() {
+			u, quarantined := p.quarantined[addr]
+			if quarantined && !time.Now().Before(u) {
+				delete(p.quarantined, addr)
+				quarantined = false
+			}
+			if !quarantined {
+				ok = false
+			} else if until.IsZero() || u.Before(until) {
+				until = u
+			}
+		}
+		p.mu.Unlock()
+		if !ok {
+			return true
+		}
+
+		timer := time.NewTimer(time.Until(until))
+		select {
+		case <-timer.C:
+		case <-stop:
+			timer.Stop()
+			return false
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlappingAddressIsQuarantined(t *testing.T) {
+	quarantined := make(chan string, 1)
+	dialed := make(chan time.Time, 10)
+	p, err := pool.NewPool(pool.Config{
+		Size:    1,
+		Address: "device:1234",
+		Quarantine: &pool.Quarantine{
+			Failures: 2,
+			Window:   time.Second,
+			Period:   50 * time.Millisecond,
+			OnQuarantine: func(addr string, until time.Time) {
+				quarantined <- addr
+			},
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			dialed <- time.Now()
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+	<-dialed
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, errors.New("reset")))
+	<-dialed
+
+	c, err = p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	released := time.Now()
+	require.Nil(t, p.Release(c, errors.New("reset")))
+	require.Equal(t, "device:1234", <-quarantined)
+
+	// The replacement isn't created until the quarantine is over
+	require.True(t, (<-dialed).Sub(released) >= 40*time.Millisecond)
+	<-p.Close()
+}
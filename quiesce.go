@@ -0,0 +1,68 @@
+package pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQuiesced is matched by the QuiescedError that is returned when trying to get a connection
+// during a maintenance window, use errors.Is to check for it
+var ErrQuiesced = errors.New("pool is quiesced")
+
+// QuiescedError is returned when trying to get a connection while the pool is quiesced
+type QuiescedError struct {
+	// Until is when the maintenance window ends
+	Until time.Time
+}
+
+func (e *QuiescedError) Error() string {
+	return fmt.Sprintf("%s until %s", ErrQuiesced, e.Until.Format(time.RFC3339))
+}
+
+// Is lets errors.Is match the error against ErrQuiesced
+func (e *QuiescedError) Is(target error) bool {
+	return target == ErrQuiesced
+}
+
+// Quiesce starts a maintenance window that lasts until the given time, for example while a
+// device reboots. Get calls fail straight away with a QuiescedError, idle connections are
+// closed and checked out ones are closed as they are released, any still checked out when
+// the window ends are forcibly closed. Once the window is over the pool is initialized again.
+// ErrPoolClosed or ErrDraining is returned if the pool is closed or being drained
+func (p *ConnectionPool) Quiesce(until time.Time) error {
+	p.mu.Lock()
+	if err := p.unavailableLocked(); err != nil {
+		p.mu.Unlock()
+		return err
+	}
+	p.quiescedUntil = until
+	p.mu.Unlock()
+
+	p.goLabeled("quiesce", func() {
+		ctx, cancel := context.WithDeadline(context.Background(), until)
+		defer cancel()
+
+		_ = p.Drain(ctx)
+		<-ctx.Done()
+
+		p.mu.Lock()
+		p.quiescedUntil = time.Time{}
+		closed := p.closed
+		p.mu.Unlock()
+		if !closed {
+			p.Init()
+		}
+	})
+	return nil
+}
+
+// ScheduleQuiesce calls Quiesce at start to begin a maintenance window lasting until end.
+// The returned timer can be stopped to cancel the window before it starts, to repeat it,
+// for example every night, schedule the next one once it has finished
+func (p *ConnectionPool) ScheduleQuiesce(start, end time.Time) *time.Timer {
+	return time.AfterFunc(time.Until(start), func() {
+		_ = p.Quiesce(end)
+	})
+}
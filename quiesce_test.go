@@ -0,0 +1,53 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuiesceClosesConnectionsThenInitsAgain(t *testing.T) {
+	var dials, closes int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 2,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return &mockConn{CloseCalled: func(*mockConn) {
+				atomic.AddInt32(&closes, 1)
+			}}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	until := time.Now().Add(50 * time.Millisecond)
+	timer := p.ScheduleQuiesce(time.Now(), until)
+	defer timer.Stop()
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&closes) == 2
+	}, time.Second, time.Millisecond)
+
+	_, err = p.Get(time.Second, pool.GetOptions{})
+	require.True(t, errors.Is(err, pool.ErrQuiesced))
+	var qerr *pool.QuiescedError
+	require.True(t, errors.As(err, &qerr))
+	require.True(t, qerr.Until.Equal(until))
+
+	require.Eventually(t, func() bool {
+		return p.Stats().Idle == 2
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int32(4), atomic.LoadInt32(&dials))
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, nil))
+	<-p.Close()
+}
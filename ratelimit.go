@@ -0,0 +1,61 @@
+package pool
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// rateLimitConn delays writes to keep to a rate, see RateLimitConn
+type rateLimitConn struct {
+	net.Conn
+
+	// tokens is the number of writes that can be made straight away, it goes negative
+	// while writes are waiting for their turn. It is topped up at rate per second from last
+	// up to burst
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// RateLimitConn wraps c so writes are made at no more than rate a second, with bursts of up
+// to burst writes after a quiet period, for devices that stop responding if commands arrive
+// too quickly. Each call to Write counts as one write however many bytes it sends, writes
+// over the limit wait for their turn. A burst less than 1 is treated as 1. The pool wraps its
+// connections with it when Config.WriteRateLimit is set
+func RateLimitConn(c net.Conn, rate float64, burst int) net.Conn {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimitConn{Conn: c, rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+func (c *rateLimitConn) Write(b []byte) (int, error) {
+	time.Sleep(c.reserve())
+	return c.Conn.Write(b)
+}
+
+// NetConn returns the wrapped connection
+func (c *rateLimitConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// reserve takes a token for a write, returning how long the write has to wait for it
+func (c *rateLimitConn) reserve() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.tokens += now.Sub(c.last).Seconds() * c.rate
+	if c.tokens > c.burst {
+		c.tokens = c.burst
+	}
+	c.last = now
+	c.tokens--
+	if c.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-c.tokens / c.rate * float64(time.Second))
+}
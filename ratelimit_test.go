@@ -0,0 +1,71 @@
+package pool_test
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCounter is a connection that counts the writes made on it
+type writeCounter struct {
+	net.Conn
+	writes int32
+}
+
+func (c *writeCounter) Write(b []byte) (int, error) {
+	atomic.AddInt32(&c.writes, 1)
+	return len(b), nil
+}
+
+func (c *writeCounter) Close() error {
+	return nil
+}
+
+func TestRateLimitConnSpacesOutWrites(t *testing.T) {
+	wc := &writeCounter{}
+	c := pool.RateLimitConn(wc, 50, 2)
+
+	// The burst goes straight away, the rest at 50 a second
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		_, err := c.Write([]byte("on\r\n"))
+		require.Nil(t, err)
+	}
+	require.True(t, time.Since(start) < 15*time.Millisecond)
+	for i := 0; i < 3; i++ {
+		_, err := c.Write([]byte("on\r\n"))
+		require.Nil(t, err)
+	}
+	elapsed := time.Since(start)
+	require.True(t, elapsed >= 55*time.Millisecond, elapsed)
+	require.True(t, elapsed < 500*time.Millisecond, elapsed)
+	require.Equal(t, int32(5), atomic.LoadInt32(&wc.writes))
+}
+
+func TestWriteRateLimitAppliesToPooledConnections(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size:           1,
+		WriteRateLimit: 20,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &writeCounter{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	defer c.Close()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := c.Write([]byte("status\r\n"))
+		require.Nil(t, err)
+	}
+	require.True(t, time.Since(start) >= 95*time.Millisecond)
+}
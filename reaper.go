@@ -0,0 +1,75 @@
+package pool
+
+import "time"
+
+// reap closes idle connections that have been idle for longer than Config.IdleTimeout or
+// open for longer than Config.MaxConnLifetime until stop is closed. Connections that
+// were idle too long are replaced when they are next needed, apart from any needed to keep
+// Config.MinIdle idle connections, connections that were too old are replaced straight away
+func (p *ConnectionPool) reap(stop chan struct{}) {
+	ticker := time.NewTicker(p.reapInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		p.mu.Lock()
+		var stale, retired []*pooledConn
+		now := time.Now()
+		idle := p.idle[:0]
+		for _, pc := range p.idle {
+			switch {
+			case p.retiredLocked(pc, now):
+				retired = append(retired, pc)
+			case p.Config.IdleTimeout > 0 && now.Sub(pc.idleSince) > p.Config.IdleTimeout:
+				stale = append(stale, pc)
+			default:
+				idle = append(idle, pc)
+			}
+		}
+		p.idle = idle
+		p.mu.Unlock()
+
+		for _, pc := range stale {
+			p.reportEvict(pc, evictIdle, nil)
+			p.discard(pc, evictIdle)
+		}
+		for _, pc := range retired {
+			p.reportEvict(pc, evictRetired, nil)
+			p.discard(pc, evictRetired)
+			p.refill()
+		}
+		if len(stale) > 0 {
+			p.mu.Lock()
+			p.topUpLocked()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// reapInterval returns how often reap checks the idle connections, which is half of the
+// shortest timeout it enforces
+func (p *ConnectionPool) reapInterval() time.Duration {
+	d := p.Config.IdleTimeout
+	if d == 0 || (p.Config.MaxConnLifetime > 0 && p.Config.MaxConnLifetime < d) {
+		d = p.Config.MaxConnLifetime
+	}
+	return d / 2
+}
+
+// retiredLocked returns true if the connection has been open for longer than
+// Config.MaxConnLifetime, checked out Config.MaxConnUses times or is connected to an address
+// the pool has moved away from, p.mu must be held
+func (p *ConnectionPool) retiredLocked(pc *pooledConn, now time.Time) bool {
+	if !p.usingLocked(pc.addr) {
+		return true
+	}
+	if p.Config.MaxConnUses > 0 && pc.uses >= p.Config.MaxConnUses {
+		return true
+	}
+	return p.Config.MaxConnLifetime > 0 && now.Sub(pc.createdAt) > p.Config.MaxConnLifetime
+}
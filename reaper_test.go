@@ -0,0 +1,131 @@
+package pool_test
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdleConnectionsAreReapedAndRedialedOnDemand(t *testing.T) {
+	var dials, closes int32
+	p, err := pool.NewPool(pool.Config{
+		Size:        2,
+		IdleTimeout: 20 * time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return &mockConn{CloseCalled: func(*mockConn) {
+				atomic.AddInt32(&closes, 1)
+			}}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+	require.Equal(t, int32(2), atomic.LoadInt32(&dials))
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&closes) == 2
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, 0, p.Stats().Idle)
+	require.Equal(t, int32(2), atomic.LoadInt32(&dials))
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Equal(t, int32(3), atomic.LoadInt32(&dials))
+	require.Nil(t, p.Release(c, nil))
+	<-p.Close()
+}
+
+func TestOldConnectionsAreReplacedOnRelease(t *testing.T) {
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Size:            1,
+		MaxConnLifetime: time.Hour,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c1, nil))
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.True(t, c1.Conn == c2.Conn)
+	require.Nil(t, p.Release(c2, nil))
+	<-p.Close()
+}
+
+func TestConnectionsAreRetiredAfterMaxConnLifetime(t *testing.T) {
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Size:            1,
+		MaxConnLifetime: 20 * time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	// Checked out connections are replaced when they come back
+	c1, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	time.Sleep(30 * time.Millisecond)
+	require.Nil(t, p.Release(c1, nil))
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.False(t, c1.Conn == c2.Conn)
+	require.Nil(t, p.Release(c2, nil))
+
+	// Idle ones are replaced in the background
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&dials) >= 4
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, 1, p.Stats().Size)
+	<-p.Close()
+}
+
+func TestConnectionsAreRetiredAfterMaxConnUses(t *testing.T) {
+	var dials int32
+	p, err := pool.NewPool(pool.Config{
+		Size:        1,
+		MaxConnUses: 2,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c1, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c1, nil))
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.True(t, c1.Conn == c2.Conn)
+	require.Nil(t, p.Release(c2, nil))
+
+	c3, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.False(t, c2.Conn == c3.Conn)
+	require.Equal(t, int32(2), atomic.LoadInt32(&dials))
+	require.Nil(t, p.Release(c3, nil))
+	<-p.Close()
+}
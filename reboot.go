@@ -0,0 +1,61 @@
+package pool
+
+import (
+	"context"
+	"time"
+)
+
+// RebootDetection makes the pool treat every one of its connections being marked as bad within
+// Window as the device having rebooted. Rather than each connection being replaced on its own,
+// the pool closes all of them, calls OnReboot once if it is set, then tries a single connection
+// until it succeeds before creating the rest
+type RebootDetection struct {
+	Window   time.Duration
+	OnReboot func()
+}
+
+// detectRebootLocked records that a connection has been marked as bad and starts recovering
+// from a reboot once all of them have been within the window, p.mu must be held
+func (p *ConnectionPool) detectRebootLocked() {
+	rd := p.Config.RebootDetection
+	if rd == nil || p.rebooting {
+		return
+	}
+
+	now := time.Now()
+	marks := p.rebootMarks
+	for len(marks) > 0 && now.Sub(marks[0]) > rd.Window {
+		marks = marks[1:]
+	}
+	p.rebootMarks = append(marks, now)
+	if len(p.rebootMarks) < p.size {
+		return
+	}
+
+	p.rebootMarks = nil
+	p.rebooting = true
+	p.goLabeled("reboot-recovery", func() { p.recoverReboot(rd.OnReboot) })
+}
+
+// recoverReboot replaces every connection after the device has rebooted, waiting for a first
+// connection to succeed before creating the rest
+func (p *ConnectionPool) recoverReboot(onReboot func()) {
+	p.InvalidateAll()
+	if onReboot != nil {
+		p.safely("OnReboot", onReboot)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.dialLocked(context.Background(), dialHooks{done: func(ok bool) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.rebooting = false
+		if ok && !p.Config.Lazy && p.unavailableLocked() == nil {
+			for p.healthyLocked()+p.pending < p.size {
+				p.dialLocked(context.Background(), dialHooks{reconnect: true})
+			}
+		}
+	}})
+}
@@ -0,0 +1,45 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRebootIsDetectedWhenEveryConnectionFails(t *testing.T) {
+	var dials, reboots int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 3,
+		RebootDetection: &pool.RebootDetection{
+			Window: time.Second,
+			OnReboot: func() {
+				atomic.AddInt32(&reboots, 1)
+			},
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	conns, err := p.GetN(3, time.Second)
+	require.Nil(t, err)
+	for _, c := range conns {
+		require.Nil(t, p.Release(c, errors.New("connection reset")))
+	}
+
+	require.Eventually(t, func() bool {
+		return p.Stats().Idle == 3 && p.Health().State == pool.Healthy
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int32(1), atomic.LoadInt32(&reboots))
+	<-p.Close()
+}
@@ -0,0 +1,146 @@
+package pool
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// dial creates a connection to the address chosen by pickLocked, returning the connection and
+// the address
+func (p *ConnectionPool) dial() (net.Conn, string, error) {
+	p.mu.Lock()
+	addr := p.pickLocked()
+	if p.dialing == nil {
+		p.dialing = make(map[string]int)
+	}
+	p.dialing[addr]++
+	p.mu.Unlock()
+
+	start := time.Now()
+	c, addr, err := p.dialTo(addr)
+	p.mu.Lock()
+	if p.dialing[addr]--; p.dialing[addr] == 0 {
+		delete(p.dialing, addr)
+	}
+	p.scoreLocked(addr, time.Since(start), err)
+	moved := p.dialResultLocked(addr, err)
+	p.mu.Unlock()
+	if moved {
+		p.migrate()
+	}
+	return c, addr, err
+}
+
+// dialTo calls Config.NewConnection with Address set to the address from dialAddress for
+// addr, wraps the connection with Config.WrapConn, then DeadlineConn and RateLimitConn if
+// they are configured, and calls Config.OnConnect. A panic in any of these stages is turned
+// into an error naming the stage, closing the connection if it had been made, so the attempt
+// is retried like any other failure instead of losing the connection
+func (p *ConnectionPool) dialTo(addr string) (c net.Conn, _ string, err error) {
+	start := time.Now()
+	defer func() {
+		took := time.Since(start)
+		p.mu.Lock()
+		p.dialTimes.observe(took)
+		p.dials++
+		if err != nil {
+			p.dialErrors++
+		}
+		p.mu.Unlock()
+		p.count(MetricDials, 1)
+		p.timing(MetricDialTime, took)
+		p.reportDial(addr, took, err)
+		if err != nil {
+			p.count(MetricDialFailures, 1)
+			p.forgetAddress(addr)
+		}
+	}()
+
+	p.mu.Lock()
+	cfg := p.Config
+	p.mu.Unlock()
+	if cfg.Address, err = p.dialAddress(addr); err != nil {
+		return nil, addr, err
+	}
+	if cfg.Address != addr {
+		cfg.keepServerName(addr)
+	}
+	err = guard("NewConnection", func() (err error) {
+		c, err = cfg.NewConnection(cfg)
+		return err
+	})
+	if err != nil {
+		return nil, addr, err
+	}
+
+	raw := c
+	err = guard("tuning TCP connection", func() error {
+		if err := cfg.tuneTCP(c); err != nil {
+			return fmt.Errorf("tuning TCP connection: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		raw.Close()
+		return nil, addr, err
+	}
+	for i, wrap := range cfg.WrapConn {
+		err = guard(fmt.Sprintf("WrapConn[%d]", i), func() error {
+			c = wrap(c)
+			return nil
+		})
+		if err != nil {
+			raw.Close()
+			return nil, addr, err
+		}
+	}
+	if cfg.ReadTimeout > 0 || cfg.WriteTimeout > 0 {
+		c = DeadlineConn(c, cfg.ReadTimeout, cfg.WriteTimeout)
+	}
+	if cfg.WriteRateLimit > 0 {
+		c = RateLimitConn(c, cfg.WriteRateLimit, cfg.WriteBurst)
+	}
+	if cfg.OnConnect == nil {
+		return c, addr, nil
+	}
+	if err = check("OnConnect", cfg.OnConnect, c); err != nil {
+		c.Close()
+		return nil, addr, fmt.Errorf("connection handshake failed: %w", err)
+	}
+	return c, addr, nil
+}
+
+// guard calls fn, turning a panic into an error naming the stage that panicked
+func guard(stage string, fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s panicked: %v", stage, r)
+		}
+	}()
+	return fn()
+}
+
+// check calls a callback that checks a connection, such as Config.Ping, turning a panic into
+// an error so the connection fails the check
+func check(name string, fn func(net.Conn) error, c net.Conn) error {
+	return guard(name, func() error { return fn(c) })
+}
+
+// safely calls a callback from one of the pool's own goroutines, logging a panic to
+// Config.Logger, or the standard logger if it isn't set, rather than letting it crash the
+// program
+func (p *ConnectionPool) safely(name string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			if p.log != nil {
+				p.log.Error("callback panicked", slog.String("callback", name), slog.Any("panic", r))
+				return
+			}
+			log.Printf("pool: %s panicked: %v", name, r)
+		}
+	}()
+	fn()
+}
@@ -0,0 +1,68 @@
+package pool
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+)
+
+// resolvedAddr is a cached lookup of the host in an address
+type resolvedAddr struct {
+	addr    string
+	expires time.Time
+}
+
+// dialAddress returns the address a new connection to from should use. When
+// Config.DNSCacheTTL is greater than 0 and from is a host name, the name is looked up and the
+// result cached for the TTL, otherwise from is returned unchanged and resolved by
+// NewConnection on every dial. A tls:// prefix is kept on the resolved address
+func (p *ConnectionPool) dialAddress(from string) (string, error) {
+	if p.Config.DNSCacheTTL <= 0 {
+		return from, nil
+	}
+	hostPort, isTLS := strings.CutPrefix(from, tlsScheme)
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil || net.ParseIP(host) != nil {
+		return from, nil
+	}
+
+	p.mu.Lock()
+	cached, ok := p.resolved[from]
+	p.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.addr, nil
+	}
+
+	lookup := p.Config.LookupHost
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupHost
+	}
+	addrs, err := lookup(context.Background(), host)
+	if err != nil {
+		return "", err
+	}
+	if len(addrs) == 0 {
+		return "", &net.DNSError{Err: "no addresses found", Name: host, IsNotFound: true}
+	}
+
+	addr := net.JoinHostPort(addrs[0], port)
+	if isTLS {
+		addr = tlsScheme + addr
+	}
+	p.mu.Lock()
+	if p.resolved == nil {
+		p.resolved = make(map[string]resolvedAddr)
+	}
+	p.resolved[from] = resolvedAddr{addr: addr, expires: time.Now().Add(p.Config.DNSCacheTTL)}
+	p.mu.Unlock()
+	return addr, nil
+}
+
+// forgetAddress drops the cached lookup of addr after a failed dial, so the next attempt
+// looks the host up again in case the device has moved to a new address
+func (p *ConnectionPool) forgetAddress(addr string) {
+	p.mu.Lock()
+	delete(p.resolved, addr)
+	p.mu.Unlock()
+}
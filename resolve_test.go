@@ -0,0 +1,99 @@
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSCacheTTLReusesLookup(t *testing.T) {
+	var lookups int32
+	dialed := make(chan string, 3)
+	p, err := pool.NewPool(pool.Config{
+		Size:        3,
+		Address:     "device.local:4000",
+		DNSCacheTTL: time.Minute,
+		LookupHost: func(ctx context.Context, host string) ([]string, error) {
+			atomic.AddInt32(&lookups, 1)
+			require.Equal(t, "device.local", host)
+			return []string{"10.0.0.5"}, nil
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			dialed <- cfg.Address
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	<-p.Init()
+	for i := 0; i < 3; i++ {
+		require.Equal(t, "10.0.0.5:4000", <-dialed)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&lookups))
+	require.Equal(t, "device.local:4000", p.Config.Address)
+	<-p.Close()
+}
+
+func TestDNSCacheDroppedAfterFailedDial(t *testing.T) {
+	var mu sync.Mutex
+	ip := "10.0.0.5"
+	p, err := pool.NewPool(pool.Config{
+		Size:          1,
+		Address:       "device.local:4000",
+		DNSCacheTTL:   time.Hour,
+		RetryDuration: time.Millisecond,
+		LookupHost: func(ctx context.Context, host string) ([]string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			return []string{ip}, nil
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if cfg.Address != net.JoinHostPort(ip, "4000") {
+				return nil, errors.New("no route to host")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	// The device gets a new address, the cached one no longer works
+	mu.Lock()
+	ip = "10.0.0.9"
+	mu.Unlock()
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	p.Release(c, errors.New("connection reset"))
+
+	c, err = p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, nil))
+	<-p.Close()
+}
+
+func TestDNSCacheTTLLeavesIPAddressesAlone(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size:        1,
+		Address:     "127.0.0.1:4000",
+		DNSCacheTTL: time.Minute,
+		LookupHost: func(ctx context.Context, host string) ([]string, error) {
+			return nil, errors.New("unexpected lookup")
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			require.Equal(t, "127.0.0.1:4000", cfg.Address)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	<-p.Close()
+}
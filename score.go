@@ -0,0 +1,96 @@
+package pool
+
+import "time"
+
+// scoreWeight is how much each connection attempt moves an address's dial success rate and
+// latency, older attempts count for less and less
+const scoreWeight = 0.3
+
+// minScore stops an address that keeps failing from being left out completely, so it is
+// still tried now and then and can recover its score
+const minScore = 0.05
+
+// AddressHealth describes how connection attempts to one of Config.Addresses have gone
+// recently
+type AddressHealth struct {
+	// DialSuccessRate is between 0 and 1, a weighted average of recent connection attempts
+	// where the latest count the most
+	DialSuccessRate float64 `json:"dial_success_rate"`
+
+	// DialLatency is a weighted average of how long recent successful connection attempts
+	// took, zero if none have succeeded
+	DialLatency time.Duration `json:"dial_latency_ns"`
+}
+
+// scoreLocked records the result of a connection attempt to addr that took d, p.mu must be
+// held
+func (p *ConnectionPool) scoreLocked(addr string, d time.Duration, err error) {
+	if len(p.Config.Addresses) == 0 {
+		return
+	}
+	if p.health == nil {
+		p.health = make(map[string]AddressHealth)
+	}
+
+	h, ok := p.health[addr]
+	if !ok {
+		h.DialSuccessRate = 1
+	}
+	success := 0.0
+	if err == nil {
+		success = 1
+		if h.DialLatency == 0 {
+			h.DialLatency = d
+		} else {
+			h.DialLatency += time.Duration(scoreWeight * float64(d-h.DialLatency))
+		}
+	}
+	h.DialSuccessRate += scoreWeight * (success - h.DialSuccessRate)
+	p.health[addr] = h
+}
+
+// weightsLocked returns how strongly each address should be preferred for new connections
+// when Config.Balance is BalanceHealth. An address's weight is its dial success rate, scaled
+// down by how much slower it is to connect to than the fastest address, p.mu must be held
+func (p *ConnectionPool) weightsLocked(addrs []string) map[string]float64 {
+	var fastest time.Duration
+	for _, addr := range addrs {
+		if l := p.health[addr].DialLatency; l > 0 && (fastest == 0 || l < fastest) {
+			fastest = l
+		}
+	}
+
+	weights := make(map[string]float64, len(addrs))
+	for _, addr := range addrs {
+		h, ok := p.health[addr]
+		w := 1.0
+		if ok {
+			w = h.DialSuccessRate
+			if h.DialLatency > 0 {
+				w *= float64(fastest) / float64(h.DialLatency)
+			}
+		}
+		if w < minScore {
+			w = minScore
+		}
+		weights[addr] = w
+	}
+	return weights
+}
+
+// addressHealthLocked returns a copy of the health of each of Config.Addresses, or nil if it
+// isn't set, p.mu must be held
+func (p *ConnectionPool) addressHealthLocked() map[string]AddressHealth {
+	if len(p.Config.Addresses) == 0 {
+		return nil
+	}
+	health := make(map[string]AddressHealth, len(p.Config.Addresses))
+	for _, addr := range p.Config.Addresses {
+		h, ok := p.health[addr]
+		if !ok {
+			h.DialSuccessRate = 1
+		}
+		health[addr] = h
+	}
+	return health
+}
@@ -0,0 +1,84 @@
+package pool
+
+import (
+	"context"
+	"fmt"
+)
+
+// Resize changes the number of connections the pool maintains while it is in use. Growing the
+// pool creates the extra connections in the background, lazy pools create them on demand.
+// Shrinking it closes surplus idle connections straight away and surplus checked out
+// connections as they are released. Config.Size is left as the initial size, Stats reports
+// the current one
+func (p *ConnectionPool) Resize(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("size must be greater than 0, got %d", n)
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPoolClosed
+	}
+
+	p.size = n
+	if !p.Config.Lazy {
+		for p.healthyLocked()+p.pending < p.size {
+			p.dialLocked(context.Background(), dialHooks{})
+		}
+	}
+
+	var surplus []*pooledConn
+	for p.open-len(surplus) > p.maxSizeLocked() && len(p.idle) > 0 {
+		surplus = append(surplus, p.idle[len(p.idle)-1])
+		p.idle = p.idle[:len(p.idle)-1]
+	}
+	p.mu.Unlock()
+
+	for _, pc := range surplus {
+		p.discard(pc, evictSurplus)
+	}
+	return nil
+}
+
+// healthyLocked returns the number of open connections that haven't been marked as bad, p.mu
+// must be held
+func (p *ConnectionPool) healthyLocked() int {
+	n := 0
+	for pc := range p.conns {
+		if pc.isHealthy() {
+			n++
+		}
+	}
+	return n
+}
+
+// maxSizeLocked returns the most connections the pool can have open, p.mu must be held
+func (p *ConnectionPool) maxSizeLocked() int {
+	if p.Config.MaxSize > p.size {
+		return p.Config.MaxSize
+	}
+	return p.size
+}
+
+// growLocked creates enough connections for everybody that is waiting, as long as the pool
+// has room for them, p.mu must be held
+func (p *ConnectionPool) growLocked() {
+	for need := p.waitingForLocked() - len(p.idle) - p.pending; need > 0 && p.open+p.pending < p.maxSizeLocked(); need-- {
+		p.dialLocked(context.Background(), dialHooks{})
+	}
+}
+
+// topUpLocked creates connections until there are at least Config.MinIdle idle connections,
+// as long as the pool has room for them, p.mu must be held
+func (p *ConnectionPool) topUpLocked() {
+	for len(p.idle)+p.pending < p.Config.MinIdle && p.open+p.pending < p.maxSizeLocked() {
+		p.dialLocked(context.Background(), dialHooks{})
+	}
+}
+
+// surplusLocked returns true if the pool has grown past its size and a released connection is
+// not needed, either by a waiter or to keep MinIdle idle connections, p.mu must be held
+func (p *ConnectionPool) surplusLocked() bool {
+	return p.open > p.size && len(p.waiters) == 0 && len(p.idle) >= p.Config.MinIdle
+}
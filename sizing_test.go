@@ -0,0 +1,176 @@
+package pool_test
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolGrowsToMaxSizeAndShrinksBack(t *testing.T) {
+	var newCount int32
+	p, err := pool.NewPool(pool.Config{
+		Size:    1,
+		MaxSize: 3,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	var conns []*pool.Connection
+	for i := 0; i < 3; i++ {
+		c, err := p.Get(time.Second, pool.GetOptions{})
+		require.Nil(t, err)
+		conns = append(conns, c)
+	}
+	require.Equal(t, int32(3), atomic.LoadInt32(&newCount))
+
+	_, err = p.Get(10*time.Millisecond, pool.GetOptions{})
+	require.ErrorIs(t, err, pool.ErrTimeout)
+
+	// Connections above Size are closed as they come back
+	for _, c := range conns {
+		require.Nil(t, p.Release(c, nil))
+	}
+	require.Len(t, p.Dump().Conns, 1)
+	require.Equal(t, 1, p.Stats().Idle)
+}
+
+func TestPoolKeepsMinIdleConnectionsReady(t *testing.T) {
+	var newCount int32
+	p, err := pool.NewPool(pool.Config{
+		Size:    1,
+		MaxSize: 4,
+		MinIdle: 1,
+		Lazy:    true,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	<-p.Init()
+	require.Nil(t, p.WaitReady(context.Background(), 1))
+
+	// Taking the warm connection starts another one in the background
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.WaitReady(context.Background(), 2))
+	require.Equal(t, 1, p.Stats().Idle)
+
+	// Only one idle connection is needed, so the surplus is closed on release
+	require.Nil(t, p.Release(c, nil))
+	require.Len(t, p.Dump().Conns, 1)
+	require.Equal(t, int32(2), atomic.LoadInt32(&newCount))
+}
+
+func TestSizingConfigIsValidated(t *testing.T) {
+	newConn := func(cfg pool.Config) (net.Conn, error) {
+		return &mockConn{}, nil
+	}
+
+	_, err := pool.NewPool(pool.Config{Size: 2, MaxSize: 1, NewConnection: newConn})
+	require.NotNil(t, err)
+	_, err = pool.NewPool(pool.Config{Size: 2, MinIdle: 3, NewConnection: newConn})
+	require.NotNil(t, err)
+	_, err = pool.NewPool(pool.Config{Size: 2, MaxSize: 4, MinIdle: 3, NewConnection: newConn})
+	require.Nil(t, err)
+}
+
+func TestResizeGrowsAndShrinksThePool(t *testing.T) {
+	var newCount int32
+	p, err := pool.NewPool(pool.Config{
+		Size: 2,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&newCount, 1)
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	require.Nil(t, p.Resize(4))
+	require.Nil(t, p.WaitReady(context.Background(), 4))
+	require.Equal(t, 4, p.Stats().Size)
+	require.Equal(t, int32(4), atomic.LoadInt32(&newCount))
+
+	c, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+
+	// The idle connections are closed straight away, the checked out one on release
+	require.Nil(t, p.Resize(1))
+	require.Len(t, p.Dump().Conns, 1)
+	s := p.Stats()
+	require.Equal(t, 1, s.Size)
+	require.Equal(t, 0, s.Idle)
+	require.Equal(t, 1, s.InUse)
+	require.Nil(t, p.Release(c, nil))
+	s = p.Stats()
+	require.Equal(t, 1, s.Size)
+	require.Equal(t, 1, s.Idle)
+	require.Equal(t, 0, s.InUse)
+
+	require.NotNil(t, p.Resize(0))
+	<-p.Close()
+	require.Equal(t, pool.ErrPoolClosed, p.Resize(2))
+}
+
+func TestAutoScaleGrowsUnderLoadAndShrinksWhenIdle(t *testing.T) {
+	resized := make(chan int, 10)
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		AutoScale: &pool.AutoScaleConfig{
+			Min:               1,
+			Max:               2,
+			Interval:          5 * time.Millisecond,
+			ShrinkUtilization: 0.1,
+			OnResize: func(oldSize, newSize int) {
+				resized <- newSize
+			},
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	// Somebody waiting for a connection makes the pool grow
+	c1, err := p.Get(time.Millisecond, pool.GetOptions{})
+	require.Nil(t, err)
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Equal(t, 2, <-resized)
+
+	// Once nothing is in use it shrinks back to Min
+	require.Nil(t, p.Release(c1, nil))
+	require.Nil(t, p.Release(c2, nil))
+	require.Equal(t, 1, <-resized)
+	require.Equal(t, 1, p.Stats().Size)
+	<-p.Close()
+}
+
+func TestAutoScaleConfigIsValidated(t *testing.T) {
+	_, err := pool.NewPool(pool.Config{
+		Size:      1,
+		AutoScale: &pool.AutoScaleConfig{Min: 2, Max: 1, Interval: time.Second},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.NotNil(t, err)
+}
@@ -0,0 +1,152 @@
+// Package sshtunnel dials pool connections through an SSH jump host, for installations that
+// are only reachable over SSH. It is kept separate from the pool package so the pool itself
+// doesn't depend on golang.org/x/crypto/ssh
+package sshtunnel
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrClosed is returned by Dial once the Dialer has been closed
+var ErrClosed = errors.New("ssh tunnel closed")
+
+// Dialer opens connections from an SSH jump host, sharing one SSH connection between them.
+// The SSH connection is made by the first dial and made again by the next dial after it
+// drops, so the pool's own retries bring the tunnel back
+type Dialer struct {
+	addr      string
+	config    *ssh.ClientConfig
+	keepAlive time.Duration
+
+	mu     sync.Mutex
+	client *ssh.Client
+	closed bool
+}
+
+// Option configures a Dialer created by New
+type Option func(*Dialer)
+
+// WithKeepAlive sends a keepalive request to the jump host every interval, closing the
+// tunnel if it isn't answered within the interval. Without it a tunnel dropped silently,
+// for example by a home router forgetting the NAT mapping, is only noticed when the devices
+// stop responding
+func WithKeepAlive(interval time.Duration) Option {
+	return func(d *Dialer) {
+		d.keepAlive = interval
+	}
+}
+
+// New returns a Dialer for the jump host at addr, a host:port, logging in with config
+func New(addr string, config *ssh.ClientConfig, opts ...Option) *Dialer {
+	d := &Dialer{addr: addr, config: config}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// NewConnection is a pool.Config.NewConnection that dials Config.Address from the jump host.
+// Addresses starting with tls:// aren't supported
+func (d *Dialer) NewConnection(c pool.Config) (net.Conn, error) {
+	return d.Dial("tcp", c.Address)
+}
+
+// Dial opens a connection to addr from the jump host. If the SSH connection has dropped it is
+// made again, once, before giving up
+func (d *Dialer) Dial(network, addr string) (net.Conn, error) {
+	for retried := false; ; retried = true {
+		client, err := d.connect()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := client.Dial(network, addr)
+		var refused *ssh.OpenChannelError
+		if err == nil || errors.As(err, &refused) || retried {
+			return conn, err
+		}
+		// The SSH connection itself failed, drop it and try a new one
+		d.drop(client)
+	}
+}
+
+// Close closes the SSH connection, and with it every connection opened through it
+func (d *Dialer) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	if d.client == nil {
+		return nil
+	}
+	err := d.client.Close()
+	d.client = nil
+	return err
+}
+
+// connect returns the current SSH connection, making a new one if there isn't one
+func (d *Dialer) connect() (*ssh.Client, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return nil, ErrClosed
+	}
+	if d.client != nil {
+		return d.client, nil
+	}
+
+	client, err := ssh.Dial("tcp", d.addr, d.config)
+	if err != nil {
+		return nil, err
+	}
+	d.client = client
+	go func() {
+		client.Wait()
+		d.drop(client)
+	}()
+	if d.keepAlive > 0 {
+		go d.sendKeepAlives(client)
+	}
+	return client, nil
+}
+
+// drop closes client and forgets it if it is still the current SSH connection
+func (d *Dialer) drop(client *ssh.Client) {
+	d.mu.Lock()
+	if d.client == client {
+		d.client = nil
+	}
+	d.mu.Unlock()
+	client.Close()
+}
+
+// sendKeepAlives sends a keepalive request every interval until client is closed, closing it
+// if a request isn't answered in time
+func (d *Dialer) sendKeepAlives(client *ssh.Client) {
+	ticker := time.NewTicker(d.keepAlive)
+	defer ticker.Stop()
+	for range ticker.C {
+		answered := make(chan error, 1)
+		go func() {
+			_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+			answered <- err
+		}()
+
+		timer := time.NewTimer(d.keepAlive)
+		select {
+		case err := <-answered:
+			timer.Stop()
+			if err != nil {
+				d.drop(client)
+				return
+			}
+		case <-timer.C:
+			d.drop(client)
+			return
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package sshtunnel_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/go-home-iot/connection-pool/sshtunnel"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// jumpHost is an SSH server that forwards direct-tcpip channels
+type jumpHost struct {
+	addr   string
+	logins int32
+
+	mu    sync.Mutex
+	conns []*ssh.ServerConn
+}
+
+// dropAll closes every SSH connection to the jump host
+func (h *jumpHost) dropAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, c := range h.conns {
+		c.Close()
+	}
+	h.conns = nil
+}
+
+func startJumpHost(t *testing.T, password string) *jumpHost {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.Nil(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.Nil(t, err)
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if string(pass) != password {
+				return nil, fmt.Errorf("wrong password for %s", c.User())
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { l.Close() })
+	h := &jumpHost{addr: l.Addr().String()}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go h.serve(c, config)
+		}
+	}()
+	t.Cleanup(h.dropAll)
+	return h
+}
+
+func (h *jumpHost) serve(c net.Conn, config *ssh.ServerConfig) {
+	conn, chans, reqs, err := ssh.NewServerConn(c, config)
+	if err != nil {
+		c.Close()
+		return
+	}
+	atomic.AddInt32(&h.logins, 1)
+	h.mu.Lock()
+	h.conns = append(h.conns, conn)
+	h.mu.Unlock()
+	go ssh.DiscardRequests(reqs)
+
+	for ch := range chans {
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if ch.ChannelType() != "direct-tcpip" || ssh.Unmarshal(ch.ExtraData(), &target) != nil {
+			ch.Reject(ssh.UnknownChannelType, "not supported")
+			continue
+		}
+		device, err := net.Dial("tcp", net.JoinHostPort(target.Host, fmt.Sprint(target.Port)))
+		if err != nil {
+			ch.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		tunnel, requests, err := ch.Accept()
+		if err != nil {
+			device.Close()
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		go func() {
+			io.Copy(tunnel, device)
+			tunnel.Close()
+		}()
+		go func() {
+			io.Copy(device, tunnel)
+			device.Close()
+		}()
+	}
+}
+
+// echoServer starts a server that writes back whatever it is sent
+func echoServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(c, c)
+				c.Close()
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func echo(t *testing.T, c net.Conn) {
+	_, err := c.Write([]byte("ping"))
+	require.Nil(t, err)
+	b := make([]byte, 4)
+	_, err = io.ReadFull(c, b)
+	require.Nil(t, err)
+	require.Equal(t, "ping", string(b))
+}
+
+func clientConfig(password string) *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		User:            "hub",
+		Auth:            []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         time.Second,
+	}
+}
+
+func TestPoolConnectsThroughJumpHost(t *testing.T) {
+	host := startJumpHost(t, "secret")
+	device := echoServer(t)
+	d := sshtunnel.New(host.addr, clientConfig("secret"))
+	defer d.Close()
+
+	p, err := pool.NewPool(pool.Config{Size: 2, Address: device, NewConnection: d.NewConnection})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	echo(t, c)
+	require.Nil(t, p.Release(c, nil))
+
+	// Both connections share one SSH connection
+	require.Equal(t, int32(1), atomic.LoadInt32(&host.logins))
+}
+
+func TestDialerReconnectsAfterTunnelDrops(t *testing.T) {
+	host := startJumpHost(t, "secret")
+	device := echoServer(t)
+	d := sshtunnel.New(host.addr, clientConfig("secret"))
+	defer d.Close()
+
+	c, err := d.Dial("tcp", device)
+	require.Nil(t, err)
+	echo(t, c)
+
+	host.dropAll()
+	_, err = c.Read(make([]byte, 1))
+	require.NotNil(t, err)
+
+	c, err = d.Dial("tcp", device)
+	require.Nil(t, err)
+	echo(t, c)
+	require.Equal(t, int32(2), atomic.LoadInt32(&host.logins))
+
+	require.Nil(t, d.Close())
+	_, err = d.Dial("tcp", device)
+	require.ErrorIs(t, err, sshtunnel.ErrClosed)
+}
+
+func TestDialerReportsFailures(t *testing.T) {
+	host := startJumpHost(t, "secret")
+
+	_, err := sshtunnel.New(host.addr, clientConfig("wrong")).Dial("tcp", echoServer(t))
+	require.ErrorContains(t, err, "unable to authenticate")
+
+	d := sshtunnel.New(host.addr, clientConfig("secret"))
+	defer d.Close()
+	_, err = d.Dial("tcp", "127.0.0.1:1")
+	var refused *ssh.OpenChannelError
+	require.ErrorAs(t, err, &refused)
+	require.Equal(t, int32(1), atomic.LoadInt32(&host.logins))
+}
@@ -0,0 +1,41 @@
+package pool
+
+import (
+	"context"
+	"time"
+)
+
+// promoteStandbyLocked moves a standby connection into the pool to take the place of one that
+// has been thrown away, then starts creating a new standby connection. It returns false if
+// there are no standby connections, p.mu must be held
+func (p *ConnectionPool) promoteStandbyLocked() bool {
+	if len(p.standby) == 0 || p.closed || p.draining {
+		return false
+	}
+
+	pc := p.standby[len(p.standby)-1]
+	p.standby = p.standby[:len(p.standby)-1]
+	pc.createdAt = time.Now()
+	p.conns[pc] = struct{}{}
+	p.open++
+	p.notifyLocked()
+	p.putLocked(pc)
+	p.topUpStandbyLocked()
+	return true
+}
+
+// topUpStandbyLocked starts creating standby connections until there are Config.StandbyCount
+// of them, p.mu must be held
+func (p *ConnectionPool) topUpStandbyLocked() {
+	for len(p.standby)+p.standbyPending < p.Config.StandbyCount {
+		p.dialLocked(context.Background(), dialHooks{standby: true})
+	}
+}
+
+// takeStandbyLocked removes all of the standby connections so the caller can close them once
+// it has released p.mu, p.mu must be held
+func (p *ConnectionPool) takeStandbyLocked() []*pooledConn {
+	standby := p.standby
+	p.standby = nil
+	return standby
+}
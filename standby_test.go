@@ -0,0 +1,50 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandbyConnectionReplacesABadOneStraightAway(t *testing.T) {
+	var dials, closes int32
+	p, err := pool.NewPool(pool.Config{
+		Size:         1,
+		StandbyCount: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return &mockConn{CloseCalled: func(*mockConn) {
+				atomic.AddInt32(&closes, 1)
+			}}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+	require.Eventually(t, func() bool {
+		return p.Stats().Standby == 1
+	}, time.Second, time.Millisecond)
+	require.Equal(t, 1, p.Stats().Idle)
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, errors.New("connection reset")))
+
+	// The standby connection is idle straight away and a new standby is created
+	stats := p.Stats()
+	require.Equal(t, 1, stats.Idle)
+	require.Eventually(t, func() bool {
+		return p.Stats().Standby == 1
+	}, time.Second, time.Millisecond)
+	require.Equal(t, int32(3), atomic.LoadInt32(&dials))
+
+	<-p.Close()
+	require.Equal(t, int32(3), atomic.LoadInt32(&closes))
+	require.Equal(t, 0, p.Stats().Standby)
+}
@@ -0,0 +1,85 @@
+package pool
+
+import "time"
+
+// Stats is a snapshot of the current state of the pool. It can be marshaled to JSON as is,
+// durations are in nanoseconds
+type Stats struct {
+	// Size is the configured number of connections in the pool
+	Size int `json:"size"`
+
+	// Idle is the number of connections waiting in the pool to be used
+	Idle int `json:"idle"`
+
+	// InUse is the number of connections that are currently checked out of the pool
+	InUse int `json:"in_use"`
+
+	// Standby is the number of standby connections ready to replace ones that fail, see
+	// Config.StandbyCount
+	Standby int `json:"standby"`
+
+	// Waiters is the number of callers waiting for a connection
+	Waiters int `json:"waiters"`
+
+	// EstimatedWait is how long a Get called now would be expected to wait, -1 if there
+	// isn't enough history to estimate it, see EstimatedWait
+	EstimatedWait time.Duration `json:"estimated_wait_ns"`
+
+	// Dials is the number of connection attempts made since the pool was created,
+	// DialFailures is how many of them failed
+	Dials        int64 `json:"dials"`
+	DialFailures int64 `json:"dial_failures"`
+
+	// Timeouts is the number of Get calls that gave up because their timeout or context
+	// deadline passed before a connection was available
+	Timeouts int64 `json:"timeouts"`
+
+	// WaitTime is the total time Get calls have spent waiting for the connections they got
+	WaitTime time.Duration `json:"wait_time_ns"`
+
+	// WaitTimes is how long Get calls waited for the connections they got and DialTimes is
+	// how long connection attempts took, using Config.LatencyBuckets
+	WaitTimes Histogram `json:"wait_times"`
+	DialTimes Histogram `json:"dial_times"`
+
+	// Lifetimes is how long connections were open for before they were evicted, because
+	// they went bad, failed a health check, timed out or were retired, using
+	// Config.LifetimeBuckets. Connections closed by Close aren't counted
+	Lifetimes Histogram `json:"lifetimes"`
+
+	// Addresses is the number of open connections to each of Config.Addresses, idle and in
+	// use, it is nil if Config.Addresses isn't set
+	Addresses map[string]int `json:"addresses,omitempty"`
+
+	// AddressHealth is how connection attempts to each of Config.Addresses have gone
+	// recently, it is nil if Config.Addresses isn't set
+	AddressHealth map[string]AddressHealth `json:"address_health,omitempty"`
+}
+
+// Stats returns a snapshot of the current pool state
+func (p *ConnectionPool) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	wait, ok := p.estimatedWaitLocked()
+	if !ok {
+		wait = -1
+	}
+	return Stats{
+		Size:          p.size,
+		Idle:          len(p.idle),
+		InUse:         len(p.inUse),
+		Standby:       len(p.standby),
+		Waiters:       len(p.waiters),
+		EstimatedWait: wait,
+		Dials:         p.dials,
+		DialFailures:  p.dialErrors,
+		Timeouts:      p.timeouts,
+		WaitTime:      p.waited,
+		WaitTimes:     p.waitTimes.snapshot(),
+		DialTimes:     p.dialTimes.snapshot(),
+		Lifetimes:     p.lifetimes.snapshot(),
+		Addresses:     p.addressCountsLocked(),
+		AddressHealth: p.addressHealthLocked(),
+	}
+}
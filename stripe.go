@@ -0,0 +1,77 @@
+package pool
+
+// addressesLocked returns the addresses the pool makes connections to, p.mu must be held
+func (p *ConnectionPool) addressesLocked() []string {
+	if len(p.Config.Addresses) > 0 {
+		return p.Config.Addresses
+	}
+	return []string{p.targetLocked()}
+}
+
+// usingLocked returns true if the pool still makes connections to addr, p.mu must be held
+func (p *ConnectionPool) usingLocked(addr string) bool {
+	for _, a := range p.addressesLocked() {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// pickLocked chooses the address for a new connection. With Config.Addresses set it is the
+// address with the fewest connections open or being made, skipping quarantined addresses
+// unless every address is quarantined, and taking turns between addresses that are level.
+// With Config.Balance set to BalanceHealth the counts are divided by each address's weight,
+// so healthier addresses get more of the connections. p.mu must be held
+func (p *ConnectionPool) pickLocked() string {
+	addrs := p.addressesLocked()
+	if len(addrs) == 1 {
+		return addrs[0]
+	}
+
+	counts := make(map[string]int, len(addrs))
+	for pc := range p.conns {
+		counts[pc.addr]++
+	}
+	for addr, n := range p.dialing {
+		counts[addr] += n
+	}
+
+	load := func(addr string) float64 { return float64(counts[addr]) }
+	if p.Config.Balance == BalanceHealth {
+		weights := p.weightsLocked(addrs)
+		load = func(addr string) float64 { return float64(counts[addr]+1) / weights[addr] }
+	}
+
+	best, bestQuarantined := -1, false
+	for i := range addrs {
+		j := (p.nextAddr + i) % len(addrs)
+		_, quarantined := p.quarantined[addrs[j]]
+		switch {
+		case best == -1,
+			bestQuarantined && !quarantined,
+			bestQuarantined == quarantined && load(addrs[j]) < load(addrs[best]):
+			best, bestQuarantined = j, quarantined
+		}
+	}
+	p.nextAddr = (best + 1) % len(addrs)
+	return addrs[best]
+}
+
+// addressCountsLocked returns the number of open connections to each of Config.Addresses,
+// or nil if it isn't set, p.mu must be held
+func (p *ConnectionPool) addressCountsLocked() map[string]int {
+	if len(p.Config.Addresses) == 0 {
+		return nil
+	}
+	counts := make(map[string]int, len(p.Config.Addresses))
+	for _, addr := range p.Config.Addresses {
+		counts[addr] = 0
+	}
+	for pc := range p.conns {
+		if _, ok := counts[pc.addr]; ok {
+			counts[pc.addr]++
+		}
+	}
+	return counts
+}
@@ -0,0 +1,112 @@
+package pool_test
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressesStripesConnections(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size:      5,
+		Addresses: []string{"10.0.0.5:23", "10.0.0.5:24"},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	counts := p.Stats().Addresses
+	require.Equal(t, 5, counts["10.0.0.5:23"]+counts["10.0.0.5:24"])
+	require.InDelta(t, counts["10.0.0.5:23"], counts["10.0.0.5:24"], 1)
+	<-p.Close()
+}
+
+func TestAddressesRebalancesReplacements(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size:      4,
+		Addresses: []string{"10.0.0.5:23", "10.0.0.5:24"},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	// Replace a connection, the new one goes to the address that is short
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, errors.New("connection reset")))
+	require.Eventually(t, func() bool {
+		counts := p.Stats().Addresses
+		return counts["10.0.0.5:23"] == 2 && counts["10.0.0.5:24"] == 2
+	}, time.Second, time.Millisecond)
+	<-p.Close()
+}
+
+func TestAddressesSkipsQuarantinedAddress(t *testing.T) {
+	var mu sync.Mutex
+	addrs := map[net.Conn]string{}
+	p, err := pool.NewPool(pool.Config{
+		Size:       2,
+		Addresses:  []string{"10.0.0.5:23", "10.0.0.5:24"},
+		Quarantine: &pool.Quarantine{Failures: 1, Window: time.Minute, Period: time.Minute},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			c := &mockConn{}
+			mu.Lock()
+			addrs[c] = cfg.Address
+			mu.Unlock()
+			return c, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	c1, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	c2, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	mu.Lock()
+	if addrs[c1.Conn] != "10.0.0.5:24" {
+		c1, c2 = c2, c1
+	}
+	mu.Unlock()
+
+	require.Nil(t, p.Release(c1, errors.New("connection reset")))
+	require.Nil(t, p.Release(c2, nil))
+	require.Eventually(t, func() bool {
+		return p.Stats().Addresses["10.0.0.5:23"] == 2
+	}, time.Second, time.Millisecond)
+	<-p.Close()
+}
+
+func TestBalanceHealthPrefersHealthyAddress(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size:          8,
+		Addresses:     []string{"10.0.0.5:23", "10.0.0.6:23"},
+		Balance:       pool.BalanceHealth,
+		RetryDuration: time.Millisecond,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			if cfg.Address == "10.0.0.6:23" {
+				time.Sleep(20 * time.Millisecond)
+				return nil, errors.New("connection refused")
+			}
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	s := p.Stats()
+	require.Equal(t, 8, s.Addresses["10.0.0.5:23"])
+	require.Equal(t, 1.0, s.AddressHealth["10.0.0.5:23"].DialSuccessRate)
+	require.True(t, s.AddressHealth["10.0.0.6:23"].DialSuccessRate < 0.5)
+	require.Zero(t, s.AddressHealth["10.0.0.6:23"].DialLatency)
+	<-p.Close()
+}
@@ -0,0 +1,50 @@
+package pool
+
+import "net"
+
+// tcpConn returns the TCP connection behind c, looking through wrappers such as *tls.Conn
+// that have a NetConn method, or nil if there isn't one
+func tcpConn(c net.Conn) *net.TCPConn {
+	for {
+		switch conn := c.(type) {
+		case *net.TCPConn:
+			return conn
+		case interface{ NetConn() net.Conn }:
+			c = conn.NetConn()
+		default:
+			return nil
+		}
+	}
+}
+
+// tuneTCP applies the TCP settings in the config to the socket behind c. Connections that
+// aren't TCP are left alone
+func (c Config) tuneTCP(conn net.Conn) error {
+	tc := tcpConn(conn)
+	if tc == nil {
+		return nil
+	}
+
+	if err := tc.SetNoDelay(!c.TCPDelay); err != nil {
+		return err
+	}
+	if c.TCPSendBuffer > 0 {
+		if err := tc.SetWriteBuffer(c.TCPSendBuffer); err != nil {
+			return err
+		}
+	}
+	if c.TCPReceiveBuffer > 0 {
+		if err := tc.SetReadBuffer(c.TCPReceiveBuffer); err != nil {
+			return err
+		}
+	}
+	if c.KeepAliveInterval > 0 || c.KeepAliveCount > 0 {
+		return tc.SetKeepAliveConfig(net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     c.KeepAlive,
+			Interval: c.KeepAliveInterval,
+			Count:    c.KeepAliveCount,
+		})
+	}
+	return nil
+}
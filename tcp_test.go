@@ -0,0 +1,111 @@
+//go:build linux
+
+package pool_test
+
+import (
+	"crypto/tls"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+// sockopts reads the TCP settings of the socket behind c
+func sockopts(t *testing.T, c *net.TCPConn) map[string]int {
+	raw, err := c.SyscallConn()
+	require.Nil(t, err)
+	opts := map[string]int{}
+	raw.Control(func(fd uintptr) {
+		for name, opt := range map[string][2]int{
+			"nodelay":  {syscall.IPPROTO_TCP, syscall.TCP_NODELAY},
+			"sndbuf":   {syscall.SOL_SOCKET, syscall.SO_SNDBUF},
+			"rcvbuf":   {syscall.SOL_SOCKET, syscall.SO_RCVBUF},
+			"keepidle": {syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE},
+			"keepintv": {syscall.IPPROTO_TCP, syscall.TCP_KEEPINTVL},
+			"keepcnt":  {syscall.IPPROTO_TCP, syscall.TCP_KEEPCNT},
+		} {
+			v, err := syscall.GetsockoptInt(int(fd), opt[0], opt[1])
+			require.Nil(t, err)
+			opts[name] = v
+		}
+	})
+	return opts
+}
+
+func TestTCPSettingsAreAppliedToEveryConnection(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	dialed := make(chan *net.TCPConn, 1)
+	p, err := pool.NewPool(pool.Config{
+		Size:              1,
+		TCPDelay:          true,
+		TCPSendBuffer:     64 << 10,
+		TCPReceiveBuffer:  32 << 10,
+		KeepAlive:         30 * time.Second,
+		KeepAliveInterval: 5 * time.Second,
+		KeepAliveCount:    3,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			c, err := net.Dial("tcp", l.Addr().String())
+			if err == nil {
+				dialed <- c.(*net.TCPConn)
+			}
+			return c, err
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	opts := sockopts(t, <-dialed)
+	require.Equal(t, 0, opts["nodelay"])
+	// Linux doubles the buffer sizes to leave room for its own bookkeeping
+	require.Equal(t, 2*64<<10, opts["sndbuf"])
+	require.Equal(t, 2*32<<10, opts["rcvbuf"])
+	require.Equal(t, 30, opts["keepidle"])
+	require.Equal(t, 5, opts["keepintv"])
+	require.Equal(t, 3, opts["keepcnt"])
+}
+
+func TestNagleIsOffByDefaultBehindTLS(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	go func() {
+		if c, err := l.Accept(); err == nil {
+			defer c.Close()
+		}
+	}()
+
+	dialed := make(chan *net.TCPConn, 1)
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			c, err := net.DialTCP("tcp", nil, l.Addr().(*net.TCPAddr))
+			if err != nil {
+				return nil, err
+			}
+			c.SetNoDelay(false)
+			dialed <- c
+			return tls.Client(c, &tls.Config{InsecureSkipVerify: true}), nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	require.Equal(t, 1, sockopts(t, <-dialed)["nodelay"])
+}
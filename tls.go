@@ -0,0 +1,109 @@
+package pool
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// tlsScheme is the prefix of addresses DefaultNewConnection dials with TLS
+const tlsScheme = "tls://"
+
+// TLSConfig configures the TLS connections made by DefaultNewConnection
+type TLSConfig struct {
+	// Config is the base configuration for every connection, it is cloned before the
+	// settings below are applied. If it is nil the defaults of crypto/tls are used
+	Config *tls.Config
+
+	// ServerName is the host name the device's certificate is checked against, if it is
+	// empty the host in Address is used, even when DNSCacheTTL has replaced it with an IP
+	// address for the dial
+	ServerName string
+
+	// InsecureSkipVerify turns off checking the device's certificate, for devices that only
+	// have a self-signed one. The connection is still encrypted but anyone on the network
+	// can pretend to be the device
+	InsecureSkipVerify bool
+
+	// ClientCertificate if set is called on every dial for the certificate to present to
+	// devices that ask for one, so hubs with short-lived certificates can rotate them
+	// without recreating the pool. An error fails the dial. See LoadClientCertificate
+	ClientCertificate func() (*tls.Certificate, error)
+}
+
+// LoadClientCertificate returns a TLSConfig.ClientCertificate that loads the certificate and
+// key from the PEM files on every dial, so a certificate renewed on disk is used from the
+// next connection on
+func LoadClientCertificate(certFile, keyFile string) func() (*tls.Certificate, error) {
+	return func() (*tls.Certificate, error) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &cert, nil
+	}
+}
+
+// clientConfig returns the configuration for a new connection to addr
+func (t *TLSConfig) clientConfig(addr string) (*tls.Config, error) {
+	cfg := &tls.Config{}
+	if t != nil && t.Config != nil {
+		cfg = t.Config.Clone()
+	}
+	if t != nil && t.ServerName != "" {
+		cfg.ServerName = t.ServerName
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = addr
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			cfg.ServerName = host
+		}
+	}
+	if t == nil {
+		return cfg, nil
+	}
+	if t.InsecureSkipVerify {
+		cfg.InsecureSkipVerify = true
+	}
+	if t.ClientCertificate != nil {
+		cert, err := t.ClientCertificate()
+		if err != nil {
+			return nil, fmt.Errorf("client certificate: %w", err)
+		}
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return cert, nil
+		}
+	}
+	return cfg, nil
+}
+
+// keepServerName makes sure a TLS connection to an address that has been resolved to an IP
+// address still checks the certificate against the host name in from, by setting
+// TLSConfig.ServerName on a copy of the TLS settings if it isn't set already
+func (c *Config) keepServerName(from string) {
+	from, isTLS := strings.CutPrefix(from, tlsScheme)
+	if !isTLS && c.TLS == nil {
+		return
+	}
+	if c.TLS != nil && c.TLS.ServerName != "" {
+		return
+	}
+	host, _, err := net.SplitHostPort(from)
+	if err != nil {
+		return
+	}
+	t := TLSConfig{}
+	if c.TLS != nil {
+		t = *c.TLS
+	}
+	t.ServerName = host
+	c.TLS = &t
+}
+
+// splitTLS returns the address without its tls:// prefix and whether the connection should
+// use TLS, either because of the prefix or because Config.TLS is set
+func splitTLS(c Config) (string, bool) {
+	addr, ok := strings.CutPrefix(c.Address, tlsScheme)
+	return addr, ok || c.TLS != nil
+}
@@ -0,0 +1,190 @@
+package pool_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolDialsTLSAddresses(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "hello")
+	}))
+	defer srv.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+
+	p, err := pool.NewPool(pool.Config{
+		Size:    1,
+		Address: "tls://" + srv.Listener.Addr().String(),
+		TLS:     &pool.TLSConfig{Config: &tls.Config{RootCAs: roots}, ServerName: "example.com"},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	conn, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	_, err = fmt.Fprint(conn, "GET / HTTP/1.0\r\nHost: example.com\r\n\r\n")
+	require.Nil(t, err)
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	require.Nil(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+	require.Nil(t, p.Release(conn, nil))
+}
+
+func TestDefaultNewConnectionChecksCertificates(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	_, err := pool.DefaultNewConnection(pool.Config{Address: "tls://" + addr})
+	var unknown x509.UnknownAuthorityError
+	require.ErrorAs(t, err, &unknown)
+
+	c, err := pool.DefaultNewConnection(pool.Config{Address: addr, TLS: &pool.TLSConfig{InsecureSkipVerify: true}})
+	require.Nil(t, err)
+	require.True(t, c.(*tls.Conn).ConnectionState().HandshakeComplete)
+	c.Close()
+}
+
+// writeCertificate writes a new self-signed certificate for name and its key to the PEM files
+func writeCertificate(t *testing.T, name, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	require.Nil(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.Nil(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+}
+
+func TestClientCertificateIsLoadedOnEveryDial(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCertificate(t, "bridge", certFile, keyFile)
+	serverCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.Nil(t, err)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAnyClientCert,
+	})
+	require.Nil(t, err)
+	defer l.Close()
+	presented := make(chan string, 2)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			tc := c.(*tls.Conn)
+			if tc.Handshake() == nil {
+				presented <- tc.ConnectionState().PeerCertificates[0].Subject.CommonName
+			}
+			c.Close()
+		}
+	}()
+
+	cfg := pool.Config{
+		Address: l.Addr().String(),
+		TLS: &pool.TLSConfig{
+			InsecureSkipVerify: true,
+			ClientCertificate:  pool.LoadClientCertificate(certFile, keyFile),
+		},
+	}
+	for _, name := range []string{"hub-1", "hub-2"} {
+		writeCertificate(t, name, certFile, keyFile)
+		c, err := pool.DefaultNewConnection(cfg)
+		require.Nil(t, err)
+		require.Equal(t, name, <-presented)
+		c.Close()
+	}
+
+	require.Nil(t, os.Remove(keyFile))
+	_, err = pool.DefaultNewConnection(cfg)
+	require.ErrorIs(t, err, os.ErrNotExist)
+	require.ErrorContains(t, err, "client certificate")
+}
+
+func TestDNSCacheTTLKeepsTheTLSServerName(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeCertificate(t, "bridge.local", certFile, keyFile)
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.Nil(t, err)
+
+	serverNames := make(chan string, 1)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverNames <- hello.ServerName
+			return nil, nil
+		},
+		Certificates: []tls.Certificate{cert},
+	})
+	require.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.(*tls.Conn).Handshake()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	var lookups int32
+	p, err := pool.NewPool(pool.Config{
+		Size:        1,
+		Address:     "tls://bridge.local:" + port,
+		DNSCacheTTL: time.Minute,
+		LookupHost: func(ctx context.Context, host string) ([]string, error) {
+			atomic.AddInt32(&lookups, 1)
+			require.Equal(t, "bridge.local", host)
+			return []string{"127.0.0.1"}, nil
+		},
+		TLS: &pool.TLSConfig{InsecureSkipVerify: true},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	require.Equal(t, "bridge.local", <-serverNames)
+	require.Equal(t, int32(1), atomic.LoadInt32(&lookups))
+	require.Equal(t, 1, p.Stats().Idle)
+}
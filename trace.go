@@ -0,0 +1,47 @@
+package pool
+
+import (
+	"context"
+	"time"
+)
+
+// GetTrace holds hooks that are called during a GetContext call, attach one to the context
+// passed to GetContext with WithGetTrace. It is intended for tracing libraries, such as the
+// otelpool package, that want to record what the call did
+type GetTrace struct {
+	// Done is called once GetContext has either got a connection or given up
+	Done func(GetInfo)
+}
+
+// GetInfo describes a finished GetContext call
+type GetInfo struct {
+	// Waited is how long the call took
+	Waited time.Duration
+
+	// Dialed is true if the connection was created while the call was waiting for it,
+	// rather than already being open
+	Dialed bool
+
+	// Err is the error the call returned, nil if it got a connection
+	Err error
+}
+
+type getTraceKey struct{}
+
+// WithGetTrace returns a context that makes GetContext call the hooks in trace
+func WithGetTrace(ctx context.Context, trace *GetTrace) context.Context {
+	return context.WithValue(ctx, getTraceKey{}, trace)
+}
+
+// getTrace returns the GetTrace attached to the context, nil if there isn't one
+func getTrace(ctx context.Context) *GetTrace {
+	trace, _ := ctx.Value(getTraceKey{}).(*GetTrace)
+	return trace
+}
+
+// done calls the Done hook if there is one
+func (t *GetTrace) done(info GetInfo) {
+	if t != nil && t.Done != nil {
+		t.Done(info)
+	}
+}
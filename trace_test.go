@@ -0,0 +1,104 @@
+package pool_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetTraceReportsDials(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		Lazy: true,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+
+	var infos []pool.GetInfo
+	ctx := pool.WithGetTrace(context.Background(), &pool.GetTrace{
+		Done: func(info pool.GetInfo) { infos = append(infos, info) },
+	})
+
+	// The first call has to create the connection, the second reuses it
+	c, err := p.GetContext(ctx, pool.GetOptions{})
+	require.Nil(t, err)
+	require.Nil(t, p.Release(c, nil))
+	c, err = p.GetContext(ctx, pool.GetOptions{})
+	require.Nil(t, err)
+
+	timeout, cancel := context.WithTimeout(ctx, time.Millisecond)
+	defer cancel()
+	_, err = p.GetContext(timeout, pool.GetOptions{})
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	require.Len(t, infos, 3)
+	require.True(t, infos[0].Dialed)
+	require.False(t, infos[1].Dialed)
+	require.Nil(t, infos[1].Err)
+	require.Equal(t, context.DeadlineExceeded, infos[2].Err)
+	require.True(t, infos[2].Waited >= time.Millisecond)
+	require.Nil(t, p.Release(c, nil))
+	<-p.Close()
+}
+
+func TestGetOptionsTraceWorksWithoutAContext(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	var infos []pool.GetInfo
+	trace := &pool.GetTrace{
+		Done: func(info pool.GetInfo) { infos = append(infos, info) },
+	}
+	c, err := p.Get(time.Second, pool.GetOptions{Trace: trace})
+	require.Nil(t, err)
+	_, err = p.Get(time.Millisecond, pool.GetOptions{Trace: trace})
+	require.ErrorIs(t, err, pool.ErrTimeout)
+	require.Len(t, infos, 2)
+	require.Nil(t, infos[0].Err)
+	require.NotNil(t, infos[1].Err)
+	require.Nil(t, p.Release(c, nil))
+}
+
+func TestAfterReleaseIsCalledHoweverTheLeaseEnds(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	var released []error
+	for _, release := range []func(*pool.Connection) error{
+		func(c *pool.Connection) error { return p.Release(c, nil) },
+		(*pool.Connection).Close,
+		(*pool.Connection).Discard,
+	} {
+		c, err := p.Get(time.Second, pool.GetOptions{})
+		require.Nil(t, err)
+		c.AfterRelease(func(err error) { released = append(released, err) })
+		require.Nil(t, release(c))
+		// Releasing twice fails, so the hook isn't called again
+		require.Equal(t, pool.ErrAlreadyReleased, c.Close())
+	}
+	require.Len(t, released, 3)
+	require.Nil(t, released[0])
+	require.Nil(t, released[1])
+	require.NotNil(t, released[2])
+}
@@ -0,0 +1,60 @@
+package pool
+
+import "time"
+
+// releaseWindow is the number of recent releases EstimatedWait bases its estimate on
+const releaseWindow = 16
+
+// releaseHistory records when the most recent connections were released
+type releaseHistory struct {
+	times [releaseWindow]time.Time
+	count int
+}
+
+// add records a release at t
+func (h *releaseHistory) add(t time.Time) {
+	h.times[h.count%releaseWindow] = t
+	h.count++
+}
+
+// oldest returns the time of the oldest release in the window and the number of releases
+// in it
+func (h *releaseHistory) oldest() (time.Time, int) {
+	if h.count < releaseWindow {
+		return h.times[0], h.count
+	}
+	return h.times[h.count%releaseWindow], releaseWindow
+}
+
+// QueueDepth returns the number of callers currently waiting for a connection
+func (p *ConnectionPool) QueueDepth() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.waiters)
+}
+
+// EstimatedWait estimates how long a Get called now would wait for a connection, from how
+// many connections the queued callers are waiting for and the rate connections have
+// recently been released at, so callers can skip work that isn't urgent while the pool is
+// saturated. It is 0 if there is an idle connection for the caller. ok is false if there
+// haven't been enough releases yet to make an estimate
+func (p *ConnectionPool) EstimatedWait() (wait time.Duration, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.estimatedWaitLocked()
+}
+
+// estimatedWaitLocked is EstimatedWait, p.mu must be held
+func (p *ConnectionPool) estimatedWaitLocked() (time.Duration, bool) {
+	need := p.waitingForLocked() + 1 - len(p.idle)
+	if need <= 0 {
+		return 0, true
+	}
+	oldest, n := p.releases.oldest()
+	if n < 2 {
+		return 0, false
+	}
+	// Measuring up to now rather than to the latest release makes the estimate grow while
+	// nothing is being released
+	return time.Since(oldest) * time.Duration(need) / time.Duration(n), true
+}
@@ -0,0 +1,60 @@
+package pool_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimatedWaitFollowsReleaseRate(t *testing.T) {
+	p, err := pool.NewPool(pool.Config{
+		Size: 1,
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+	<-p.Init()
+	defer func() { <-p.Close() }()
+
+	// An idle connection means no wait
+	wait, ok := p.EstimatedWait()
+	require.True(t, ok)
+	require.Equal(t, time.Duration(0), wait)
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+	_, ok = p.EstimatedWait()
+	require.False(t, ok)
+	require.Equal(t, time.Duration(-1), p.Stats().EstimatedWait)
+
+	// Release a connection roughly every 10ms
+	for i := 0; i < 4; i++ {
+		time.Sleep(10 * time.Millisecond)
+		require.Nil(t, c.Close())
+		c, err = p.Get(time.Second, pool.GetOptions{})
+		require.Nil(t, err)
+	}
+	wait, ok = p.EstimatedWait()
+	require.True(t, ok)
+	require.True(t, wait >= 5*time.Millisecond && wait < 100*time.Millisecond, wait)
+
+	// Each queued caller adds to the wait
+	waiting := make(chan struct{})
+	go func() {
+		defer close(waiting)
+		if c, err := p.Get(time.Second, pool.GetOptions{}); err == nil {
+			c.Close()
+		}
+	}()
+	require.Eventually(t, func() bool { return p.QueueDepth() == 1 }, time.Second, time.Millisecond)
+	queued, ok := p.EstimatedWait()
+	require.True(t, ok)
+	require.True(t, queued > wait, "%s <= %s", queued, wait)
+
+	require.Nil(t, c.Close())
+	<-waiting
+}
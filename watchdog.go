@@ -0,0 +1,61 @@
+package pool
+
+import (
+	"log"
+	"log/slog"
+	"time"
+)
+
+// Stall describes a caller that has been waiting for a connection for longer than
+// Config.StallThreshold while there were idle connections that could have served it
+type Stall struct {
+	// Waited is how long the caller had been waiting, Wanted how many connections it wanted
+	Waited time.Duration
+	Wanted int
+
+	// State is the state of the pool when the stall was found
+	State PoolState
+}
+
+// watchdog looks for stalled callers each half of Config.StallThreshold until stop is closed,
+// each stalled caller is reported once
+func (p *ConnectionPool) watchdog(stop chan struct{}) {
+	ticker := time.NewTicker(p.Config.StallThreshold / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+
+		var stalls []Stall
+		now := time.Now()
+		p.mu.Lock()
+		if !p.paused {
+			for _, w := range p.waiters {
+				if waited := now.Sub(w.since); !w.stalled && waited > p.Config.StallThreshold && len(p.idle) >= w.n {
+					w.stalled = true
+					stalls = append(stalls, Stall{Waited: waited, Wanted: w.n})
+				}
+			}
+		}
+		p.mu.Unlock()
+		if len(stalls) == 0 {
+			continue
+		}
+
+		state := p.Dump()
+		for _, s := range stalls {
+			s.State = state
+			if p.Config.OnStall != nil {
+				p.safely("OnStall", func() { p.Config.OnStall(s) })
+			} else if p.log != nil {
+				p.log.Warn("caller waiting while enough connections are idle", slog.Int("wanted", s.Wanted), slog.Duration("waited", s.Waited), slog.String("state", state.String()))
+			} else {
+				log.Printf("pool %q: caller waiting for %d connections for %s while enough are idle\n%s", state.Name, s.Wanted, s.Waited, state)
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package pool_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-home-iot/connection-pool"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdogReportsCallersStuckBehindTheQueue(t *testing.T) {
+	stalls := make(chan pool.Stall, 10)
+	p, err := pool.NewPool(pool.Config{
+		Name:           "hub",
+		Size:           2,
+		StallThreshold: 10 * time.Millisecond,
+		OnStall: func(s pool.Stall) {
+			stalls <- s
+		},
+		NewConnection: func(cfg pool.Config) (net.Conn, error) {
+			return &mockConn{}, nil
+		},
+	})
+	require.Nil(t, err)
+
+	done := p.Init()
+	<-done
+
+	c, err := p.Get(time.Second, pool.GetOptions{})
+	require.Nil(t, err)
+
+	// A caller needing both connections holds up the one behind it
+	getN := make(chan error, 1)
+	go func() {
+		_, err := p.GetN(2, 100*time.Millisecond)
+		getN <- err
+	}()
+	require.Eventually(t, func() bool {
+		return p.Dump().Waiters == 1
+	}, time.Second, time.Millisecond)
+
+	_, err = p.Get(100*time.Millisecond, pool.GetOptions{})
+	require.ErrorIs(t, err, pool.ErrTimeout)
+	require.ErrorIs(t, <-getN, pool.ErrTimeout)
+
+	s := <-stalls
+	require.Equal(t, 1, s.Wanted)
+	require.True(t, s.Waited > 10*time.Millisecond)
+	require.Equal(t, "hub", s.State.Name)
+	require.Len(t, stalls, 0)
+	require.Nil(t, p.Release(c, nil))
+	<-p.Close()
+}